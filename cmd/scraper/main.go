@@ -1,9 +1,16 @@
 package scraper
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
 	"github.com/DaRealFreak/epub-scraper/pkg/raven"
+	"github.com/DaRealFreak/epub-scraper/pkg/scaffold"
 	"github.com/DaRealFreak/epub-scraper/pkg/scraper"
 	"github.com/DaRealFreak/epub-scraper/pkg/update"
 	"github.com/DaRealFreak/epub-scraper/pkg/version"
@@ -13,8 +20,12 @@ import (
 
 // Scraper returns the CLI Scraper struct
 type Scraper struct {
-	logLevel string
-	rootCmd  *cobra.Command
+	logLevel     string
+	noProgress   bool
+	telemetry    bool
+	telemetryDSN string
+	options      scraper.Options
+	rootCmd      *cobra.Command
 }
 
 // NewScraper returns the pointer to an initialized CLI Scraper struct
@@ -28,41 +39,9 @@ func NewScraper() *Scraper {
 				"to work with as many websites as possible",
 			Version: version.VERSION,
 			Args:    cobra.MinimumNArgs(1),
-			Run: func(cmd *cobra.Command, args []string) {
-				app, err := scraper.NewScraper()
-				if err != nil {
-					log.Fatal(err)
-				}
-
-				for _, s := range args {
-					if _, err := os.Stat(s); os.IsNotExist(err) {
-						log.Fatalf("%s is neither a file or directory", s)
-					}
-
-					// #nosec
-					file, err := os.Open(s)
-					if err != nil {
-						log.Fatal(err)
-					}
-
-					fi, err := file.Stat()
-					if err != nil {
-						log.Fatal(err)
-					}
-
-					err = file.Close()
-					switch {
-					case err != nil:
-						log.Fatal(err)
-					case fi.IsDir():
-						app.HandleDirectory(s)
-					default:
-						app.HandleFile(s)
-					}
-				}
-			},
 		},
 	}
+	app.rootCmd.Run = app.scrape
 
 	app.rootCmd.PersistentFlags().StringVarP(
 		&app.logLevel,
@@ -71,34 +50,228 @@ func NewScraper() *Scraper {
 		log.InfoLevel.String(),
 		"log level (debug, info, warn, error, fatal, panic)",
 	)
+	app.rootCmd.PersistentFlags().BoolVar(
+		&app.telemetry,
+		"telemetry",
+		false,
+		"report occurring errors to sentry, can also be enabled in the user configuration",
+	)
+	app.rootCmd.PersistentFlags().StringVar(
+		&app.telemetryDSN,
+		"telemetry-dsn",
+		"",
+		"DSN of an own sentry instance to report occurring errors to, implies --telemetry",
+	)
+
+	app.rootCmd.Flags().BoolVar(
+		&app.noProgress,
+		"no-progress",
+		false,
+		"don't draw the progress bar of the chapter extraction",
+	)
+	app.rootCmd.Flags().StringVar(
+		&app.options.CookieFile,
+		"cookies",
+		"",
+		"Netscape/Mozilla cookies.txt file to load into the session, overrides the configured cookie file",
+	)
+	app.rootCmd.Flags().BoolVar(
+		&app.options.Partial,
+		"partial",
+		false,
+		"write the already extracted chapters as incomplete epub if the run gets interrupted",
+	)
+	app.rootCmd.Flags().BoolVar(
+		&app.options.Resume,
+		"resume",
+		false,
+		"continue the interrupted previous run from the already extracted chapters of the checkpoint",
+	)
+	app.rootCmd.Flags().BoolVar(
+		&app.options.DiscardCheckpoint,
+		"discard-checkpoint",
+		false,
+		"remove the checkpoint of the interrupted previous run and extract its chapters again",
+	)
+	app.rootCmd.Flags().BoolVar(
+		&app.options.SaveCookies,
+		"save-cookies",
+		false,
+		"write the updated cookies back to the cookie file after the run",
+	)
+	app.rootCmd.Flags().BoolVar(
+		&app.options.Full,
+		"full",
+		false,
+		"extract all chapters again instead of only the chapters missing in the state file",
+	)
+	app.rootCmd.Flags().BoolVar(
+		&app.options.Offline,
+		"offline",
+		false,
+		"only use cached responses without sending any requests, fails on responses missing in the cache",
+	)
+	app.rootCmd.Flags().BoolVar(
+		&app.options.Refresh,
+		"refresh",
+		false,
+		"revalidate all cached responses and update the cache with the current responses",
+	)
+	app.rootCmd.Flags().StringVar(
+		&app.options.EmojiMode,
+		"emoji-mode",
+		"",
+		"strip emojis, replace them with their short names or with images (strip, short-name, image)",
+	)
+	app.rootCmd.Flags().StringVar(
+		&app.options.EmojiVersion,
+		"emoji-version",
+		"",
+		"unicode version of the emoji data, overrides the configured unicode version (default 13.1)",
+	)
+	app.rootCmd.Flags().BoolVar(
+		&app.options.EmojiOffline,
+		"emoji-offline",
+		false,
+		"never download the emoji names, strips emojis if the names weren't downloaded in a previous run",
+	)
+	app.rootCmd.Flags().StringSliceVar(
+		&app.options.EmojiAllow,
+		"emoji-allow",
+		nil,
+		"emoji codes or code ranges to keep in addition to the configured ones, f.e. 2764,1F600..1F64F",
+	)
 
 	// add sub commands
 	app.addUpdateCommand()
+	app.addValidateCommand()
+	app.addInitCommand()
 
 	// parse all configurations before executing the main command
 	cobra.OnInitialize(app.initScraper)
 	return app
 }
 
+// scrape builds the novels of the passed configuration files and directories with the parsed options
+func (cli *Scraper) scrape(cmd *cobra.Command, args []string) {
+	// offline runs must not send any requests
+	if !cli.options.Offline {
+		update.NewUpdateChecker().CheckForAvailableUpdates()
+	}
+
+	scraperOptions := cli.options
+	var progress *progressBar
+	if !cli.noProgress && isTerminal(os.Stderr) {
+		progress = newProgressBar(os.Stderr)
+		log.SetOutput(progress)
+		scraperOptions.Observer = progress
+	}
+
+	novelScraper, err := scraper.NewScraper(scraperOptions)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, s := range args {
+		if _, err := os.Stat(s); os.IsNotExist(err) {
+			log.Fatalf("%s is neither a file or directory", s)
+		}
+
+		// #nosec
+		file, err := os.Open(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fi, err := file.Stat()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = file.Close()
+		switch {
+		case err != nil:
+			log.Fatal(err)
+		case fi.IsDir():
+			err = novelScraper.HandleDirectory(cmd.Context(), s)
+		default:
+			err = novelScraper.HandleFile(cmd.Context(), s)
+		}
+		raven.CheckError(err)
+	}
+	if progress != nil {
+		progress.finish()
+	}
+}
+
 // Execute executes the root command, entry point for the CLI application
+// only the scraping and the init command check for available updates since the other commands work without network access
 func (cli *Scraper) Execute() {
-	// check for available updates
-	update.NewUpdateChecker().CheckForAvailableUpdates()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cli.cancelOnInterrupt(cancel)
 
-	if err := cli.rootCmd.Execute(); err != nil {
+	if err := cli.rootCmd.ExecuteContext(ctx); err != nil {
 		os.Exit(-1)
 	}
 }
 
+// cancelOnInterrupt cancels the context on the first interrupt to stop sending new requests
+// and exits directly on the second interrupt
+func (cli *Scraper) cancelOnInterrupt(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	<-signals
+	log.Warning("received interrupt, finishing the current run (interrupt again to exit immediately)")
+	cancel()
+
+	<-signals
+	os.Exit(1)
+}
+
 // initScraper initializes everything the CLI application needs
 func (cli *Scraper) initScraper() {
-	// setup sentry for error logging
-	raven.SetupSentry()
-
 	// set log level
 	lvl, err := log.ParseLevel(cli.logLevel)
-	raven.CheckError(err)
+	if err != nil {
+		log.Fatal(err)
+	}
 	log.SetLevel(lvl)
+
+	// setup sentry for error logging if the user opted in
+	telemetry, err := cli.getTelemetry()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = raven.SetupSentry(telemetry); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// getTelemetry returns the telemetry options of the user configuration overridden by the passed flags
+func (cli *Scraper) getTelemetry() (config.Telemetry, error) {
+	var telemetry config.Telemetry
+	userConfigPath, err := config.GetUserConfigurationPath()
+	if err == nil {
+		userConfig, err := config.NewParser().ReadUserConfigurationFile(userConfigPath)
+		if err != nil {
+			return telemetry, fmt.Errorf("unable to read user configuration %s: %w", userConfigPath, err)
+		}
+		telemetry = userConfig.Telemetry
+	} else {
+		// the user configuration is optional, so a missing configuration directory is no error
+		log.Debugf("unable to retrieve user configuration directory: %s", err.Error())
+	}
+
+	if cli.telemetry {
+		telemetry.Enabled = true
+	}
+	if cli.telemetryDSN != "" {
+		telemetry.Enabled = true
+		telemetry.DSN = cli.telemetryDSN
+	}
+	return telemetry, nil
 }
 
 // addUpdateCommand adds the update sub command
@@ -115,3 +288,69 @@ func (cli *Scraper) addUpdateCommand() {
 	}
 	cli.rootCmd.AddCommand(addCmd)
 }
+
+// addValidateCommand adds the validate sub command
+func (cli *Scraper) addValidateCommand() {
+	validateCmd := &cobra.Command{
+		Use:   "validate [file 1] [file 2] ...",
+		Short: "validate novel configurations",
+		Long: "checks the passed configuration files for invalid regular expressions, CSS selectors and URLs " +
+			"without accessing the network",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			parser := config.NewParser()
+			issueCount := 0
+			for _, fileName := range args {
+				issues, err := parser.ValidateConfigurationFile(fileName)
+				if err != nil {
+					log.Fatal(err)
+				}
+				for _, issue := range issues {
+					log.Error(issue.String())
+				}
+				if len(issues) == 0 {
+					log.Infof("%s is valid", fileName)
+				}
+				issueCount += len(issues)
+			}
+			if issueCount > 0 {
+				log.Fatalf("found %d problem(s) in the passed configurations", issueCount)
+			}
+		},
+	}
+	cli.rootCmd.AddCommand(validateCmd)
+}
+
+// addInitCommand adds the init sub command
+func (cli *Scraper) addInitCommand() {
+	var output string
+	initCmd := &cobra.Command{
+		Use:   "init [table of content url]",
+		Short: "generate a novel configuration skeleton",
+		Long: "detects the layout of the passed table of content and its first chapter " +
+			"and generates a novel configuration skeleton to refine",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			update.NewUpdateChecker().CheckForAvailableUpdates()
+
+			scaffolder, err := scaffold.NewScaffolder()
+			raven.CheckError(err)
+			skeleton, err := scaffolder.Generate(cmd.Context(), args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			if output == "" {
+				_, err = os.Stdout.Write(skeleton)
+				raven.CheckError(err)
+				return
+			}
+			if _, err = os.Stat(output); err == nil {
+				log.Fatalf("%s already exists", output)
+			}
+			raven.CheckError(ioutil.WriteFile(output, skeleton, 0644))
+			log.Infof("novel configuration skeleton saved to %s", output)
+		},
+	}
+	initCmd.Flags().StringVarP(&output, "output", "o", "", "file to write the configuration to (default stdout)")
+	cli.rootCmd.AddCommand(initCmd)
+}
@@ -0,0 +1,124 @@
+package scraper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/scraper"
+)
+
+// width of the progress bar in characters
+const progressBarWidth = 30
+
+// progressBar draws the progress of the chapter extraction with the estimated remaining time
+// log messages are written through the progress bar to keep the progress bar below the log messages
+type progressBar struct {
+	scraper.NopObserver
+	output io.Writer
+	mutex  sync.Mutex
+	// time the first chapter URL got discovered to estimate the remaining time
+	started time.Time
+	total   int
+	done    int
+	// currently drawn line, empty if no progress bar is drawn
+	line string
+}
+
+// newProgressBar returns a progress bar drawn to the passed output
+func newProgressBar(output io.Writer) *progressBar {
+	return &progressBar{output: output}
+}
+
+// isTerminal checks if the passed file is a terminal, the progress bar is only drawn for interactive sessions
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Write writes the passed log message above the progress bar
+func (p *progressBar) Write(message []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.clear()
+	n, err := p.output.Write(message)
+	p.draw()
+	return n, err
+}
+
+// ChapterLinkDiscovered adds the discovered chapter to the total amount of chapters
+func (p *progressBar) ChapterLinkDiscovered(string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.total == 0 {
+		p.started = time.Now()
+	}
+	p.total++
+	p.clear()
+	p.draw()
+}
+
+// ChapterExtracted adds the extracted chapter to the amount of done chapters
+func (p *progressBar) ChapterExtracted(string, *scraper.ChapterData) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.done++
+	p.clear()
+	p.draw()
+}
+
+// EpubWritten removes the progress bar after the novel is done, so the next novel starts with an empty progress bar
+func (p *progressBar) EpubWritten(string) {
+	p.finish()
+}
+
+// finish removes the progress bar and resets the progress
+func (p *progressBar) finish() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.clear()
+	p.total, p.done = 0, 0
+}
+
+// draw draws the progress bar into the current line
+func (p *progressBar) draw() {
+	if p.total == 0 {
+		return
+	}
+	filled := progressBarWidth * p.done / p.total
+	p.line = fmt.Sprintf(
+		"[%s%s] %d/%d chapters%s",
+		strings.Repeat("=", filled),
+		strings.Repeat(" ", progressBarWidth-filled),
+		p.done,
+		p.total,
+		p.getETA(),
+	)
+	_, _ = fmt.Fprint(p.output, p.line)
+}
+
+// clear overwrites the currently drawn progress bar with spaces and returns to the start of the line
+// spaces are used instead of escape sequences since not every terminal supports them
+func (p *progressBar) clear() {
+	if p.line == "" {
+		return
+	}
+	_, _ = fmt.Fprint(p.output, "\r"+strings.Repeat(" ", len(p.line))+"\r")
+	p.line = ""
+}
+
+// getETA returns the estimated remaining time based on the average time per already extracted chapter
+func (p *progressBar) getETA() string {
+	if p.done == 0 || p.done >= p.total {
+		return ""
+	}
+	remaining := time.Since(p.started) / time.Duration(p.done) * time.Duration(p.total-p.done)
+	return fmt.Sprintf(", ETA %s", remaining.Round(time.Second))
+}
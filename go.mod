@@ -3,9 +3,8 @@ module github.com/DaRealFreak/epub-scraper
 go 1.15
 
 require (
-	github.com/DaRealFreak/emoji-sanitizer v1.0.2
 	github.com/PuerkitoBio/goquery v1.6.0
-	github.com/andybalholm/cascadia v1.2.0 // indirect
+	github.com/andybalholm/cascadia v1.2.0
 	github.com/blang/semver v3.5.1+incompatible
 	github.com/bmaupin/go-epub v0.5.3
 	github.com/getsentry/sentry-go v0.7.0
@@ -25,4 +24,5 @@ require (
 	google.golang.org/appengine v1.6.7 // indirect
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/yaml.v2 v2.3.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+)
+
+// compileEntries compiles the patterns and validates the options of all entries of the novel configuration
+// and the included site configurations, returns the problems of all invalid entries in the order of definition
+func (s *NovelConfig) compileEntries() (entryErrors []*EntryError) {
+	check := func(file string, path string, err error) {
+		if err != nil {
+			entryErrors = append(entryErrors, &EntryError{File: file, Path: path, Err: err})
+		}
+	}
+
+	if s.Concurrency < 0 {
+		check("", "concurrency", fmt.Errorf("concurrency can't be negative"))
+	}
+	check("", "proxy", s.Proxy.validate())
+	check("", "volumes", s.Volumes.compile())
+	check("", "emojis", s.Emojis.Validate())
+	for i := range s.Sites {
+		site := &s.Sites[i]
+		path := fmt.Sprintf("sites.%d", site.index)
+		check(site.definedIn, path, site.compile())
+		check(site.definedIn, path+".backoff", site.Backoff.validate())
+		check(site.definedIn, path+".proxy", site.Proxy.validate())
+		check(site.definedIn, path+".wayback-machine.proxy", site.WaybackMachine.Proxy.validate())
+		for _, entryError := range site.SourceContent.compile(path) {
+			entryError.File = site.definedIn
+			entryErrors = append(entryErrors, entryError)
+		}
+	}
+	for i, source := range s.Chapters {
+		path := fmt.Sprintf("chapters.%d", i)
+		if source.Toc != nil {
+			entryErrors = append(entryErrors, source.Toc.SourceContent.compile(path+".toc")...)
+		}
+		if source.Chapter != nil {
+			entryErrors = append(entryErrors, source.Chapter.SourceContent.compile(path+".chapter")...)
+		}
+	}
+	for i := range s.BackList {
+		check("", fmt.Sprintf("blacklist.%d", i), s.BackList[i].compile())
+	}
+	for i := range s.Replacements {
+		check("", fmt.Sprintf("replacements.%d", i), s.Replacements[i].compile())
+	}
+	return entryErrors
+}
+
+// compile validates the transforms of the title and chapter content and compiles their regular expressions
+func (c *SourceContent) compile(path string) (entryErrors []*EntryError) {
+	for _, transforms := range []struct {
+		path       string
+		transforms *[]Transform
+	}{
+		{path + ".title-content.transforms", c.TitleContent.Transforms},
+		{path + ".chapter-content.transforms", c.ChapterContent.Transforms},
+	} {
+		if transforms.transforms == nil {
+			continue
+		}
+		for i := range *transforms.transforms {
+			transform := &(*transforms.transforms)[i]
+			transformPath := fmt.Sprintf("%s.%d", transforms.path, i)
+			err := transform.validate()
+			if err == nil && transform.Replace != nil {
+				err = transform.Replace.compile()
+				transformPath += ".replace"
+			}
+			if err != nil {
+				entryErrors = append(entryErrors, &EntryError{Path: transformPath, Err: err})
+			}
+		}
+	}
+	return entryErrors
+}
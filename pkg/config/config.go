@@ -2,21 +2,34 @@ package config
 
 import (
 	"net/url"
+	"sync"
 
-	"github.com/DaRealFreak/epub-scraper/pkg/raven"
 	log "github.com/sirupsen/logrus"
 )
 
 // NovelConfig contains the configuration of the novel scraper
 type NovelConfig struct {
 	BaseDirectory string
+	Include       []string            `yaml:"include"`
 	General       General             `yaml:"general"`
 	Sites         []SiteConfiguration `yaml:"sites"`
 	Chapters      []Source            `yaml:"chapters"`
+	Volumes       Volumes             `yaml:"volumes"`
 	Assets        Assets              `yaml:"assets"`
-	BackList      []string            `yaml:"blacklist"`
+	BackList      []BlacklistEntry    `yaml:"blacklist"`
 	Replacements  []Replacement       `yaml:"replacements"`
 	Templates     Templates           `yaml:"templates"`
+	Cookies       Cookies             `yaml:"cookies"`
+	Proxy         Proxy               `yaml:"proxy"`
+	Cache         Cache               `yaml:"cache"`
+	Emojis        Emojis              `yaml:"emojis"`
+	// maximum amount of chapters extracted at the same time
+	Concurrency int `yaml:"concurrency"`
+	// replacements applied during the scraping process for the build report
+	appliedReplacements []AppliedReplacement
+	replacementsMutex   sync.Mutex
+	// the configuration got read or prepared already, so the site configurations are merged into the sources
+	prepared bool
 }
 
 // TitleContent contains the title selector and the title cleanup options
@@ -34,10 +47,11 @@ type ChapterContent struct {
 
 // CleanupOptions are all options related to cleaning up the extracted content of titles and chapters
 type CleanupOptions struct {
-	PrefixSelectors *[]string `yaml:"prefix-selectors"`
-	SuffixSelectors *[]string `yaml:"suffix-selectors"`
-	StripRegex      string    `yaml:"strip-regex"`
-	CleanupRegex    string    `yaml:"cleanup-regex"`
+	PrefixSelectors *[]string    `yaml:"prefix-selectors"`
+	SuffixSelectors *[]string    `yaml:"suffix-selectors"`
+	StripRegex      string       `yaml:"strip-regex"`
+	CleanupRegex    string       `yaml:"cleanup-regex"`
+	Transforms      *[]Transform `yaml:"transforms"`
 }
 
 // Pagination contains all implemented options for paginations of websites
@@ -46,30 +60,61 @@ type Pagination struct {
 	NextPageSelector *string `yaml:"next-page-selector"`
 }
 
-// GetSiteConfigFromURL retrieves the site configuration for the passed URL
+// GetSiteConfigFromURL retrieves the most specific site configuration for the passed URL
+// exact hosts are preferred over glob hosts and glob hosts over regex hosts, followed by the longest path prefix
 // will return an empty site configuration with nil values if no site configuration for host exists
 func (s *NovelConfig) GetSiteConfigFromURL(url *url.URL) *SiteConfiguration {
-	for _, site := range s.Sites {
-		if url.Host == site.Host {
-			// pin variable for scope linting
-			site := site
-			return &site
+	var (
+		bestMatch       *SiteConfiguration
+		bestSpecificity int
+	)
+	for i := range s.Sites {
+		if specificity := s.Sites[i].matchURL(url); specificity > bestSpecificity {
+			bestMatch = &s.Sites[i]
+			bestSpecificity = specificity
 		}
 	}
+	if bestMatch != nil {
+		// return a copy to keep the site configuration unchanged
+		site := *bestMatch
+		return &site
+	}
 	// return empty configuration with nil values
 	return &SiteConfiguration{}
 }
 
 // IsURLBlacklisted checks if the passed URL is blacklisted
 // it parses the passed URL and the blacklisted URLs to ignore minor differences like f.e. trailing slash
+// glob and regex entries are matched against the parsed URL, invalid URLs are never blacklisted
 func (s *NovelConfig) IsURLBlacklisted(checkedURL string) bool {
 	check, err := url.Parse(checkedURL)
-	raven.CheckError(err)
+	if err != nil {
+		return false
+	}
 	for _, listItem := range s.BackList {
-		parsedListItem, err := url.Parse(listItem)
-		raven.CheckError(err)
-		if check.String() == parsedListItem.String() {
-			log.Infof("url %s is blacklisted, skipping", check.String())
+		switch {
+		case listItem.urlPattern != nil:
+			if listItem.urlPattern.MatchString(check.String()) {
+				log.Infof("url %s is blacklisted by pattern %s, skipping", check.String(), listItem.urlPattern)
+				return true
+			}
+		case listItem.URL != "":
+			// the URLs of the blacklist are already validated while parsing the configuration
+			parsedListItem, _ := url.Parse(listItem.URL)
+			if check.String() == parsedListItem.String() {
+				log.Infof("url %s is blacklisted, skipping", check.String())
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsTitleBlacklisted checks if the passed chapter title matches any title entry of the blacklist
+func (s *NovelConfig) IsTitleBlacklisted(title string) bool {
+	for _, listItem := range s.BackList {
+		if listItem.titlePattern != nil && listItem.titlePattern.MatchString(title) {
+			log.Infof("chapter title %s is blacklisted by pattern %s, skipping", title, listItem.titlePattern)
 			return true
 		}
 	}
@@ -77,16 +122,56 @@ func (s *NovelConfig) IsURLBlacklisted(checkedURL string) bool {
 }
 
 // DoURLReplacements checks if the passed URL is getting replaced through the configuration
+// exact URL replacements are preferred over regular expression replacements, invalid URLs are never replaced
 func (s *NovelConfig) DoURLReplacements(checkedURL string) (chapterUrl string, changed bool) {
 	check, err := url.Parse(checkedURL)
-	raven.CheckError(err)
+	if err != nil {
+		return checkedURL, false
+	}
 	for _, replacement := range s.Replacements {
-		parsedReplacementURL, err := url.Parse(replacement.Url)
-		raven.CheckError(err)
+		if replacement.fromPattern != nil {
+			continue
+		}
+		// the URLs of the replacements are already validated while parsing the configuration
+		parsedReplacementURL, _ := url.Parse(replacement.Url)
 		if check.String() == parsedReplacementURL.String() {
-			log.Infof("url %s is getting replaced to %s", check.String(), replacement.ReplacementURL)
+			s.addAppliedReplacement(check.String(), replacement.ReplacementURL, replacement.rule())
 			return replacement.ReplacementURL, true
 		}
 	}
+	for _, replacement := range s.Replacements {
+		if replacement.fromPattern != nil && replacement.fromPattern.MatchString(check.String()) {
+			replacedURL := replacement.fromPattern.ReplaceAllString(check.String(), replacement.To)
+			s.addAppliedReplacement(check.String(), replacedURL, replacement.rule())
+			return replacedURL, true
+		}
+	}
 	return check.String(), false
 }
+
+// AppliedReplacements returns all unique replacements which got applied during the scraping process
+func (s *NovelConfig) AppliedReplacements() []AppliedReplacement {
+	s.replacementsMutex.Lock()
+	defer s.replacementsMutex.Unlock()
+
+	return s.appliedReplacements
+}
+
+// addAppliedReplacement logs the applied replacement and saves it for the build report if not already saved
+func (s *NovelConfig) addAppliedReplacement(originalURL string, replacedURL string, rule string) {
+	log.Infof("url %s is getting replaced to %s (rule: %s)", originalURL, replacedURL, rule)
+
+	s.replacementsMutex.Lock()
+	defer s.replacementsMutex.Unlock()
+
+	for _, applied := range s.appliedReplacements {
+		if applied.OriginalURL == originalURL && applied.ReplacedURL == replacedURL {
+			return
+		}
+	}
+	s.appliedReplacements = append(s.appliedReplacements, AppliedReplacement{
+		OriginalURL: originalURL,
+		ReplacedURL: replacedURL,
+		MatchedRule: rule,
+	})
+}
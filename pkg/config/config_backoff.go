@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// implemented backoff strategies for retried requests
+const (
+	BackoffLinear      = "linear"
+	BackoffExponential = "exponential"
+	BackoffConstant    = "constant"
+)
+
+// default delay of the backoff strategies
+const defaultBackoffDelay = time.Second
+
+// Backoff contains the options how long to wait before retrying a failed request
+type Backoff struct {
+	Strategy string        `yaml:"strategy"`
+	Delay    time.Duration `yaml:"delay"`
+	MaxDelay time.Duration `yaml:"max-delay"`
+}
+
+// validate checks the backoff strategy and the delays
+func (b *Backoff) validate() error {
+	switch b.Strategy {
+	case "", BackoffLinear, BackoffExponential, BackoffConstant:
+	default:
+		return fmt.Errorf(
+			"unknown backoff strategy %s, expected %s, %s or %s",
+			b.Strategy, BackoffLinear, BackoffExponential, BackoffConstant,
+		)
+	}
+	if b.Delay < 0 || b.MaxDelay < 0 {
+		return fmt.Errorf("backoff delays can't be negative")
+	}
+	return nil
+}
+
+// GetDelay returns the time to wait before the next try after the passed failed try (starting with 1)
+// the linear strategy is used by default, the delay defaults to one second
+func (b *Backoff) GetDelay(try int) (delay time.Duration) {
+	baseDelay := b.Delay
+	if baseDelay == 0 {
+		baseDelay = defaultBackoffDelay
+	}
+
+	switch b.Strategy {
+	case BackoffConstant:
+		delay = baseDelay
+	case BackoffExponential:
+		delay = baseDelay
+		for i := 1; i < try && (b.MaxDelay == 0 || delay < b.MaxDelay); i++ {
+			delay *= 2
+		}
+	default:
+		delay = baseDelay * time.Duration(try+1)
+	}
+	return b.LimitDelay(delay)
+}
+
+// LimitDelay returns the passed delay limited to the configured maximum delay
+func (b *Backoff) LimitDelay(delay time.Duration) time.Duration {
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		return b.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffGetDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		backoff  Backoff
+		try      int
+		expected time.Duration
+	}{
+		{"default linear first try", Backoff{}, 1, 2 * time.Second},
+		{"default linear third try", Backoff{}, 3, 4 * time.Second},
+		{"linear with delay", Backoff{Strategy: BackoffLinear, Delay: 500 * time.Millisecond}, 2, 1500 * time.Millisecond},
+		{"linear limited", Backoff{Strategy: BackoffLinear, MaxDelay: 3 * time.Second}, 5, 3 * time.Second},
+		{"constant", Backoff{Strategy: BackoffConstant, Delay: 3 * time.Second}, 4, 3 * time.Second},
+		{"exponential first try", Backoff{Strategy: BackoffExponential}, 1, time.Second},
+		{"exponential fourth try", Backoff{Strategy: BackoffExponential}, 4, 8 * time.Second},
+		{
+			"exponential limited",
+			Backoff{Strategy: BackoffExponential, Delay: time.Second, MaxDelay: 10 * time.Second},
+			20,
+			10 * time.Second,
+		},
+	}
+	for _, test := range tests {
+		if actual := test.backoff.GetDelay(test.try); actual != test.expected {
+			t.Errorf("%s: GetDelay(%d) = %s, expected %s", test.name, test.try, actual, test.expected)
+		}
+	}
+}
+
+func TestBackoffValidate(t *testing.T) {
+	tests := []struct {
+		backoff     Backoff
+		expectError bool
+	}{
+		{Backoff{}, false},
+		{Backoff{Strategy: BackoffExponential, Delay: time.Second, MaxDelay: time.Minute}, false},
+		{Backoff{Strategy: "fibonacci"}, true},
+		{Backoff{Delay: -time.Second}, true},
+	}
+	for _, test := range tests {
+		if err := test.backoff.validate(); (err != nil) != test.expectError {
+			t.Errorf("validate() of %+v returned %v, expected error: %t", test.backoff, err, test.expectError)
+		}
+	}
+}
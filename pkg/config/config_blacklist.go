@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// BlacklistEntry is a single entry of the blacklist matching either the chapter URL or the chapter title
+// plain string entries are URLs, URLs containing the wildcards "*" or "?" are used as glob pattern
+type BlacklistEntry struct {
+	URL        string `yaml:"url"`
+	Regex      string `yaml:"regex"`
+	Title      string `yaml:"title"`
+	TitleRegex string `yaml:"title-regex"`
+	// compiled patterns of the glob and regex entries
+	urlPattern   *regexp.Regexp
+	titlePattern *regexp.Regexp
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface to allow plain string entries
+// the patterns are compiled after the parsing to report all invalid entries at once
+func (e *BlacklistEntry) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
+	var plainURL string
+	if err = unmarshal(&plainURL); err != nil {
+		type plain BlacklistEntry
+		if err = unmarshal((*plain)(e)); err != nil {
+			return err
+		}
+	} else {
+		e.URL = plainURL
+	}
+	return nil
+}
+
+// compile checks that exactly one option is set and compiles the glob or regex pattern of the entry
+func (e *BlacklistEntry) compile() (err error) {
+	e.urlPattern, e.titlePattern = nil, nil
+	setOptions := 0
+	for _, option := range []string{e.URL, e.Regex, e.Title, e.TitleRegex} {
+		if option != "" {
+			setOptions++
+		}
+	}
+	if setOptions != 1 {
+		return fmt.Errorf("blacklist entries require exactly one of url, regex, title or title-regex")
+	}
+
+	switch {
+	case e.URL != "" && isGlobPattern(e.URL):
+		e.urlPattern = compileGlob(e.URL)
+	case e.URL != "":
+		_, err = url.Parse(e.URL)
+	case e.Regex != "":
+		e.urlPattern, err = compileRegex(e.Regex)
+	case e.Title != "":
+		e.titlePattern = compileGlob(e.Title)
+	case e.TitleRegex != "":
+		e.titlePattern, err = compileRegex(e.TitleRegex)
+	}
+	return err
+}
+
+// IsPattern checks if the entry uses a pattern instead of a plain URL
+func (e *BlacklistEntry) IsPattern() bool {
+	return e.urlPattern != nil || e.titlePattern != nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import "time"
+
+// default time to live of cached table of content pages, chapter pages are cached forever by default
+const defaultTocTTL = time.Hour
+
+// Cache contains the options of the persistent HTTP response cache
+// relative directories are resolved against the directory of the configuration file
+type Cache struct {
+	Disabled  bool   `yaml:"disabled"`
+	Directory string `yaml:"directory"`
+	// only serve responses from the cache without sending any request
+	Offline bool `yaml:"offline"`
+	// revalidate all cached responses, only set by the refresh flag
+	Refresh bool `yaml:"-"`
+}
+
+// CacheTTL contains the time to live of the cached responses of a site, a time to live of 0 never expires
+type CacheTTL struct {
+	Toc     *time.Duration `yaml:"toc"`
+	Chapter *time.Duration `yaml:"chapter"`
+}
+
+// GetTTL returns the time to live of cached table of content or chapter pages
+func (t *CacheTTL) GetTTL(tableOfContents bool) time.Duration {
+	switch {
+	case tableOfContents && t.Toc != nil:
+		return *t.Toc
+	case tableOfContents:
+		return defaultTocTTL
+	case t.Chapter != nil:
+		return *t.Chapter
+	default:
+		return 0
+	}
+}
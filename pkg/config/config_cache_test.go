@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheTTLGetTTL(t *testing.T) {
+	day := 24 * time.Hour
+	never := time.Duration(0)
+	tests := []struct {
+		name            string
+		ttl             CacheTTL
+		tableOfContents bool
+		expected        time.Duration
+	}{
+		{"default table of contents", CacheTTL{}, true, defaultTocTTL},
+		{"default chapter", CacheTTL{}, false, 0},
+		{"configured table of contents", CacheTTL{Toc: &day}, true, day},
+		{"configured chapter", CacheTTL{Chapter: &day}, false, day},
+		{"table of contents never expiring", CacheTTL{Toc: &never}, true, 0},
+		{"chapter ttl for table of contents", CacheTTL{Chapter: &day}, true, defaultTocTTL},
+	}
+	for _, test := range tests {
+		if actual := test.ttl.GetTTL(test.tableOfContents); actual != test.expected {
+			t.Errorf("%s: GetTTL(%t) = %s, expected %s", test.name, test.tableOfContents, actual, test.expected)
+		}
+	}
+}
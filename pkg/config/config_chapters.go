@@ -1,7 +1,9 @@
 package config
 
 // Source is the option to define the source of the chapter content, table of content or single chapter
+// all chapters of the source are added to the volume if set
 type Source struct {
+	Volume  string   `yaml:"volume"`
 	Toc     *Toc     `yaml:"toc"`
 	Chapter *Chapter `yaml:"chapter"`
 }
@@ -0,0 +1,9 @@
+package config
+
+// Cookies contains the options of the Netscape/Mozilla cookies.txt file loaded into the session
+// relative paths are resolved against the directory of the configuration file
+type Cookies struct {
+	File string `yaml:"file"`
+	// write the updated cookies back to the file after the run
+	Save bool `yaml:"save"`
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	// EmojiModeStrip strips all emojis from the chapter titles and contents
+	EmojiModeStrip = "strip"
+	// EmojiModeShortName replaces all emojis with their CLDR short names, f.e. ":grinning face:"
+	EmojiModeShortName = "short-name"
+	// EmojiModeImage replaces all emojis in the chapter contents with inline images,
+	// chapter titles can't contain images and use the CLDR short names instead
+	EmojiModeImage = "image"
+
+	// DefaultEmojiVersion is the unicode emoji version used if no version is configured
+	DefaultEmojiVersion = "13.1"
+	// DefaultEmojiImageURL is the URL of the twemoji images used for the image mode
+	DefaultEmojiImageURL = "https://cdn.jsdelivr.net/gh/twitter/twemoji@13.1.0/assets/72x72/{code}.png"
+)
+
+// EmojiVersions are all unicode emoji versions with available emoji data
+var EmojiVersions = []string{"1.0", "2.0", "3.0", "4.0", "5.0", "11.0", "12.0", "12.1", "13.0", "13.1"}
+
+// emojiCodePattern matches single emoji codes like "2764" and emoji code ranges like "1F600..1F64F"
+var emojiCodePattern = regexp.MustCompile(`^[0-9A-F]{4,6}(\.\.[0-9A-F]{4,6})?$`)
+
+// Emojis contains the options of the emoji sanitizer applied to the chapter titles and contents
+// the emoji data of all unicode versions is bundled, only the emoji names are downloaded once into the data directory
+type Emojis struct {
+	// strip, short-name or image, defaults to strip
+	Mode           string `yaml:"mode"`
+	UnicodeVersion string `yaml:"unicode-version"`
+	// never download the emoji names, emojis are stripped if the names are missing in the data directory
+	Offline bool `yaml:"offline"`
+	// directory containing the emoji data of the unicode versions, defaults to the cache directory of the user
+	// relative directories are resolved against the directory of the configuration file
+	Directory string `yaml:"directory"`
+	// emoji codes kept in addition to the common emojis like digits, "#" or "©"
+	Allow []string `yaml:"allow"`
+	// URL of the emoji images for the image mode, {code} gets replaced with the lowercase emoji codes joined by "-"
+	ImageURL string `yaml:"image-url"`
+}
+
+// Validate checks the emoji mode, the unicode version and the allowed emoji codes
+func (e *Emojis) Validate() error {
+	switch e.Mode {
+	case "", EmojiModeStrip, EmojiModeShortName, EmojiModeImage:
+	default:
+		return fmt.Errorf(
+			"emoji mode %s is not supported, use %s, %s or %s", e.Mode, EmojiModeStrip, EmojiModeShortName, EmojiModeImage,
+		)
+	}
+	if e.UnicodeVersion != "" && !e.isKnownVersion() {
+		return fmt.Errorf("unicode version %s has no emoji data, use one of %v", e.UnicodeVersion, EmojiVersions)
+	}
+	for _, code := range e.Allow {
+		if !emojiCodePattern.MatchString(code) {
+			return fmt.Errorf("allowed emoji code %s is neither a hex code like 2764 nor a range like 1F600..1F64F", code)
+		}
+	}
+	return nil
+}
+
+// GetMode returns the configured emoji mode or the strip mode if no mode is configured
+func (e *Emojis) GetMode() string {
+	if e.Mode == "" {
+		return EmojiModeStrip
+	}
+	return e.Mode
+}
+
+// GetUnicodeVersion returns the configured unicode version or the default version if no version is configured
+func (e *Emojis) GetUnicodeVersion() string {
+	if e.UnicodeVersion == "" {
+		return DefaultEmojiVersion
+	}
+	return e.UnicodeVersion
+}
+
+// GetImageURL returns the configured image URL or the twemoji image URL if no image URL is configured
+func (e *Emojis) GetImageURL() string {
+	if e.ImageURL == "" {
+		return DefaultEmojiImageURL
+	}
+	return e.ImageURL
+}
+
+// isKnownVersion checks if the configured unicode version has emoji data
+func (e *Emojis) isKnownVersion() bool {
+	for _, version := range EmojiVersions {
+		if version == e.UnicodeVersion {
+			return true
+		}
+	}
+	return false
+}
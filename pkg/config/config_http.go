@@ -0,0 +1,16 @@
+package config
+
+// HTTPOptions contains the options of the requests sent to a site
+// relative paths of the CA bundle are resolved against the directory of the file the site is defined in
+type HTTPOptions struct {
+	Headers            map[string]string `yaml:"headers"`
+	UserAgent          string            `yaml:"user-agent"`
+	Cookies            map[string]string `yaml:"cookies"`
+	CABundle           string            `yaml:"ca-bundle"`
+	InsecureSkipVerify bool              `yaml:"insecure-skip-verify"`
+}
+
+// HasTLSOptions checks if the HTTP options require a custom TLS configuration
+func (o *HTTPOptions) HasTLSOptions() bool {
+	return o.CABundle != "" || o.InsecureSkipVerify
+}
@@ -0,0 +1,8 @@
+package config
+
+// SiteLibrary is a shared collection of site configurations which can be included by novel configurations
+// libraries can include further libraries themselves
+type SiteLibrary struct {
+	Include []string            `yaml:"include"`
+	Sites   []SiteConfiguration `yaml:"sites"`
+}
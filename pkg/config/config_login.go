@@ -0,0 +1,17 @@
+package config
+
+// Login contains the options of the form based login of a site
+// the credentials are read from the configured environment variables to keep them out of the configuration files
+type Login struct {
+	URL string `yaml:"url"`
+	// optional CSS selector to the input or meta element containing the CSRF token of the login form
+	CSRFSelector  string `yaml:"csrf-selector"`
+	UsernameField string `yaml:"username-field"`
+	PasswordField string `yaml:"password-field"`
+	UsernameEnv   string `yaml:"username-env"`
+	PasswordEnv   string `yaml:"password-env"`
+	// additional static form fields
+	Fields map[string]string `yaml:"fields"`
+	// CSS selector only present on pages if we are logged in, used to detect expired logins
+	LoggedInSelector string `yaml:"logged-in-selector"`
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Proxy is the URL of a HTTP, HTTPS or SOCKS5 proxy
+type Proxy struct {
+	*url.URL
+	// configured proxy URL, parsed after the parsing of the configuration to report all invalid entries at once
+	raw string
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface to read the proxy URL from a plain string
+func (p *Proxy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return unmarshal(&p.raw)
+}
+
+// validate parses the configured proxy URL and checks the scheme and the host of the proxy URL if a proxy is set
+func (p *Proxy) validate() error {
+	if p.raw != "" {
+		proxyURL, err := url.Parse(p.raw)
+		if err != nil {
+			return err
+		}
+		p.URL, p.raw = proxyURL, ""
+	}
+	if !p.IsSet() {
+		return nil
+	}
+	switch p.URL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("proxy %s requires the scheme http, https or socks5", p.URL)
+	}
+	if p.URL.Host == "" {
+		return fmt.Errorf("proxy %s has no host", p.URL)
+	}
+	return nil
+}
+
+// IsSet checks if a proxy got configured
+func (p *Proxy) IsSet() bool {
+	return p.URL != nil
+}
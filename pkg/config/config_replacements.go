@@ -1,7 +1,47 @@
 package config
 
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
 // Replacement contains the replaced URL and their replacement
+// alternatively a regular expression can be used to rewrite all matching URLs, "$1" expands to the first group
 type Replacement struct {
 	Url            string `yaml:"url"`
 	ReplacementURL string `yaml:"replacement"`
+	FromRegex      string `yaml:"from-regex"`
+	To             string `yaml:"to"`
+	// compiled pattern of the from-regex option
+	fromPattern *regexp.Regexp
+}
+
+// AppliedReplacement contains the original and the rewritten URL of an applied replacement
+type AppliedReplacement struct {
+	OriginalURL string
+	ReplacedURL string
+	MatchedRule string
+}
+
+// compile checks the exclusive options of the replacement and compiles the regular expression
+func (r *Replacement) compile() (err error) {
+	r.fromPattern = nil
+	switch {
+	case r.Url != "" && r.FromRegex != "":
+		return fmt.Errorf("replacement can't use url %s and from-regex %s at the same time", r.Url, r.FromRegex)
+	case r.FromRegex != "":
+		r.fromPattern, err = compileRegex(r.FromRegex)
+	case r.Url != "":
+		_, err = url.Parse(r.Url)
+	}
+	return err
+}
+
+// rule returns the matching part of the replacement for logging purposes
+func (r *Replacement) rule() string {
+	if r.fromPattern != nil {
+		return r.FromRegex
+	}
+	return r.Url
 }
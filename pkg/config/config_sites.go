@@ -1,20 +1,126 @@
 package config
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// specificity of the host matching types, more specific matches are preferred
+const (
+	hostMatchRegex = iota + 1
+	hostMatchGlob
+	hostMatchExact
+)
 
 // SiteConfiguration is an optional configuration to extract the Pagination struct and the ChapterContent struct
 // into one Configuration object, allowing multiple sources of the same Host to reuse them
 // Source options have a higher priority than the SiteConfiguration options
 type SiteConfiguration struct {
 	Host           string     `yaml:"host"`
+	HostRegex      string     `yaml:"host-regex"`
+	PathPrefix     string     `yaml:"path-prefix"`
 	Pagination     Pagination `yaml:"pagination"`
 	SourceContent  `yaml:",inline"`
 	Redirects      []string       `yaml:"redirects"`
 	WaybackMachine WaybackMachine `yaml:"wayback-machine"`
+	RateLimit      time.Duration  `yaml:"rate-limit"`
+	Burst          int            `yaml:"burst"`
+	Concurrency    int            `yaml:"concurrency"`
+	MaxRetries     *int           `yaml:"max-retries"`
+	Backoff        Backoff        `yaml:"backoff"`
+	HTTP           HTTPOptions    `yaml:"http"`
+	Login          *Login         `yaml:"login"`
+	Proxy          Proxy          `yaml:"proxy"`
+	CacheTTL       CacheTTL       `yaml:"cache-ttl"`
+	// compiled host pattern for glob and regex hosts
+	hostPattern *regexp.Regexp
+	// file and position the site configuration got defined in, definedIn is empty for the novel configuration
+	definedIn string
+	index     int
 }
 
-// WaybackMachine contains the usage and version option of a site
+// WaybackMachine contains the usage, version and proxy option of a site
 type WaybackMachine struct {
 	Use     bool        `yaml:"use"`
 	Version json.Number `yaml:"version"`
+	Proxy   Proxy       `yaml:"proxy"`
+}
+
+// compile checks the options of the site configuration and compiles the host pattern
+// the nested options like the backoff or the transforms are compiled separately
+func (s *SiteConfiguration) compile() error {
+	s.hostPattern = nil
+	if s.RateLimit < 0 || s.Burst < 0 || s.Concurrency < 0 || (s.MaxRetries != nil && *s.MaxRetries < 0) {
+		return fmt.Errorf("rate-limit, burst, concurrency and max-retries of site configurations can't be negative")
+	}
+	if (s.CacheTTL.Toc != nil && *s.CacheTTL.Toc < 0) || (s.CacheTTL.Chapter != nil && *s.CacheTTL.Chapter < 0) {
+		return fmt.Errorf("cache-ttl of site configurations can't be negative")
+	}
+
+	switch {
+	case s.Host != "" && s.HostRegex != "":
+		return fmt.Errorf("site configuration can't use host %s and host-regex %s at the same time", s.Host, s.HostRegex)
+	case s.HostRegex != "":
+		re, err := compileRegex(s.HostRegex)
+		if err != nil {
+			return err
+		}
+		s.hostPattern = re
+	case isGlobPattern(s.Host):
+		s.hostPattern = compileGlob(s.Host)
+	}
+	return nil
+}
+
+// Pattern returns the host and path pattern identifying the site configuration
+func (s *SiteConfiguration) Pattern() string {
+	pattern := s.Host
+	if s.HostRegex != "" {
+		pattern = "regex:" + s.HostRegex
+	}
+	return pattern + s.PathPrefix
+}
+
+// MatchesURL checks if the site configuration is responsible for the passed URL
+func (s *SiteConfiguration) MatchesURL(u *url.URL) bool {
+	return s.matchURL(u) > 0
+}
+
+// matchURL returns the specificity of the match for the passed URL or 0 if the site configuration doesn't match
+// the host matching type (exact, glob or regex) is weighted higher than the length of the path prefix
+func (s *SiteConfiguration) matchURL(u *url.URL) int {
+	if u == nil || !s.matchesPathPrefix(u.Path) {
+		return 0
+	}
+
+	var hostMatch int
+	switch {
+	case s.hostPattern != nil && s.HostRegex != "":
+		if s.hostPattern.MatchString(u.Host) {
+			hostMatch = hostMatchRegex
+		}
+	case s.hostPattern != nil:
+		if s.hostPattern.MatchString(u.Host) {
+			hostMatch = hostMatchGlob
+		}
+	case s.Host != "" && strings.EqualFold(s.Host, u.Host):
+		hostMatch = hostMatchExact
+	}
+	if hostMatch == 0 {
+		return 0
+	}
+	return hostMatch<<16 + len(s.PathPrefix)
+}
+
+// matchesPathPrefix checks if the passed path starts with the path prefix on a path segment boundary,
+// so the prefix /novel matches /novel and /novel/chapter-1 but not /novels
+func (s *SiteConfiguration) matchesPathPrefix(path string) bool {
+	if !strings.HasPrefix(path, s.PathPrefix) {
+		return false
+	}
+	return strings.HasSuffix(s.PathPrefix, "/") || len(path) == len(s.PathPrefix) || path[len(s.PathPrefix)] == '/'
 }
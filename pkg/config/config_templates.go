@@ -4,6 +4,7 @@ package config
 type Templates struct {
 	ToC     TemplateToC     `yaml:"toc"`
 	Chapter TemplateChapter `yaml:"chapter"`
+	Volume  TemplateVolume  `yaml:"volume"`
 }
 
 // TemplateToC contains all templates related to the table of content page
@@ -18,3 +19,8 @@ type TemplateChapter struct {
 	Content string `yaml:"content"`
 	Title   string `yaml:"title"`
 }
+
+// TemplateVolume contains all templates related to the volume title pages
+type TemplateVolume struct {
+	Content string `yaml:"content"`
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestConfiguration writes the passed content into a novel configuration file in a temporary directory
+func writeTestConfiguration(t *testing.T, content string) string {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), "novel.yaml")
+	if err := ioutil.WriteFile(fileName, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+// readTestConfiguration reads the passed content like a novel configuration file
+func readTestConfiguration(t *testing.T, content string) *NovelConfig {
+	t.Helper()
+	novelConfig, err := NewParser().ReadConfigurationFile(writeTestConfiguration(t, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return novelConfig
+}
+
+func TestReadConfigurationFile(t *testing.T) {
+	novelConfig := readTestConfiguration(t, `
+sites:
+  - host: www.example.com
+    chapter-content:
+      content-selector: div.content
+chapters:
+  - chapter:
+      url: https://www.example.com/chapter-1
+`)
+	if novelConfig.BaseDirectory == "" || !filepath.IsAbs(novelConfig.BaseDirectory) {
+		t.Errorf("expected absolute base directory, got %q", novelConfig.BaseDirectory)
+	}
+	contentSelector := novelConfig.Chapters[0].Chapter.ContentSelector
+	if contentSelector == nil || *contentSelector != "div.content" {
+		t.Errorf("expected content selector of the site configuration, got %v", contentSelector)
+	}
+
+	// invalid configurations return an error instead of exiting
+	if _, err := NewParser().ReadConfigurationFile(writeTestConfiguration(t, `
+chapters:
+  - chapter:
+      url: "https://www.example.com/%zz"
+`)); err == nil {
+		t.Errorf("expected error for invalid chapter URL")
+	}
+
+	// empty configuration files result in an empty configuration
+	novelConfig, err := NewParser().ReadConfigurationFile(writeTestConfiguration(t, ""))
+	if err != nil || novelConfig == nil || len(novelConfig.Chapters) != 0 {
+		t.Errorf("expected empty configuration, got %+v and %v", novelConfig, err)
+	}
+}
+
+func TestPrepareConfiguration(t *testing.T) {
+	contentSelector := "div.content"
+	novelConfig := &NovelConfig{
+		Sites: []SiteConfiguration{{
+			Host:          "www.example.com",
+			SourceContent: SourceContent{ChapterContent: ChapterContent{ContentSelector: &contentSelector}},
+		}},
+		Chapters: []Source{{Chapter: &Chapter{URL: "https://www.example.com/chapter-1"}}},
+		BackList: []BlacklistEntry{{URL: "https://www.example.com/teaser-*"}},
+		Cookies:  Cookies{File: "cookies.txt"},
+	}
+	if err := NewParser().PrepareConfiguration(novelConfig); err != nil {
+		t.Fatal(err)
+	}
+	// relative paths are resolved against the working directory without base directory
+	workingDirectory, _ := filepath.Abs(".")
+	if novelConfig.BaseDirectory != workingDirectory ||
+		novelConfig.Cookies.File != filepath.Join(workingDirectory, "cookies.txt") {
+		t.Errorf("unexpected base directory %s and cookie file %s", novelConfig.BaseDirectory, novelConfig.Cookies.File)
+	}
+	selector := novelConfig.Chapters[0].Chapter.ContentSelector
+	if selector == nil || *selector != contentSelector {
+		t.Errorf("expected content selector of the site configuration, got %v", selector)
+	}
+	if !novelConfig.IsURLBlacklisted("https://www.example.com/teaser-1") {
+		t.Errorf("expected compiled blacklist entry")
+	}
+	// prepared configurations are not changed again
+	novelConfig.Cookies.File = "cookies.txt"
+	if err := NewParser().PrepareConfiguration(novelConfig); err != nil || novelConfig.Cookies.File != "cookies.txt" {
+		t.Errorf("expected unchanged prepared configuration, got %s and %v", novelConfig.Cookies.File, err)
+	}
+
+	invalidConfig := &NovelConfig{BackList: []BlacklistEntry{{Regex: "([a-"}}}
+	var entryError *EntryError
+	err := NewParser().PrepareConfiguration(invalidConfig)
+	if !errors.As(err, &entryError) || entryError.Path != "blacklist.0" {
+		t.Errorf("expected entry error of the invalid blacklist entry, got %v", err)
+	}
+}
+
+func TestGetSiteConfigFromURL(t *testing.T) {
+	novelConfig := readTestConfiguration(t, `
+sites:
+  - host: "*.example.com"
+    chapter-content:
+      content-selector: div.glob
+  - host: www.example.com
+    chapter-content:
+      content-selector: div.host
+  - host: www.example.com
+    path-prefix: /novel
+    chapter-content:
+      content-selector: div.novel
+  - host: www.example.com
+    path-prefix: /novel/side-stories/
+    chapter-content:
+      content-selector: div.side-stories
+  - host-regex: example\.org
+    chapter-content:
+      content-selector: div.regex
+`)
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://blog.example.com/chapter-1", "div.glob"},
+		{"https://www.example.com/chapter-1", "div.host"},
+		{"https://WWW.Example.com/chapter-1", "div.host"},
+		{"https://www.example.com/novel", "div.novel"},
+		{"https://www.example.com/novel/chapter-1", "div.novel"},
+		// path prefixes are matched on path segment boundaries
+		{"https://www.example.com/novels/chapter-1", "div.host"},
+		{"https://www.example.com/novel-2/chapter-1", "div.host"},
+		{"https://www.example.com/novel/side-stories/chapter-1", "div.side-stories"},
+		{"https://www.example.com/novel/side-stories", "div.novel"},
+		{"https://www.example.com/novel/side-stories-2", "div.novel"},
+		// host regular expressions are not anchored
+		{"https://www.example.org/chapter-1", "div.regex"},
+		{"https://example.org.example.net/chapter-1", "div.regex"},
+		{"https://example.net/chapter-1", ""},
+	}
+	for _, test := range tests {
+		parsedURL, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual string
+		if contentSelector := novelConfig.GetSiteConfigFromURL(parsedURL).ContentSelector; contentSelector != nil {
+			actual = *contentSelector
+		}
+		if actual != test.expected {
+			t.Errorf("site configuration of %s has content selector %q, expected %q", test.url, actual, test.expected)
+		}
+	}
+}
+
+func TestIsURLBlacklisted(t *testing.T) {
+	novelConfig := readTestConfiguration(t, `
+blacklist:
+  - https://example.com/chapter-1/
+  - url: https://example.com/teaser-*
+  - regex: ^https://example\.com/chapter-\d+-preview$
+  - title: "*Announcement*"
+`)
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"https://example.com/chapter-1/", true},
+		{"https://example.com/chapter-2/", false},
+		{"https://example.com/teaser-3", true},
+		{"https://example.com/TEASER-3", true},
+		{"https://example.com/chapter-4-preview", true},
+		{"https://example.com/chapter-4-preview/", false},
+		// title entries are never matched against URLs
+		{"https://example.com/Announcement", false},
+		{"://invalid", false},
+	}
+	for _, test := range tests {
+		if actual := novelConfig.IsURLBlacklisted(test.url); actual != test.expected {
+			t.Errorf("IsURLBlacklisted(%q) = %t, expected %t", test.url, actual, test.expected)
+		}
+	}
+}
+
+func TestIsTitleBlacklisted(t *testing.T) {
+	novelConfig := readTestConfiguration(t, `
+blacklist:
+  - title: "*Announcement*"
+  - title-regex: (?i)^chapter \d+ \(teaser\)$
+  - https://example.com/Hiatus
+`)
+	tests := []struct {
+		title    string
+		expected bool
+	}{
+		{"Release Announcement", true},
+		{"release announcement", true},
+		{"Chapter 12 (Teaser)", true},
+		{"Chapter 12", false},
+		// URL entries are never matched against titles
+		{"https://example.com/Hiatus", false},
+	}
+	for _, test := range tests {
+		if actual := novelConfig.IsTitleBlacklisted(test.title); actual != test.expected {
+			t.Errorf("IsTitleBlacklisted(%q) = %t, expected %t", test.title, actual, test.expected)
+		}
+	}
+}
+
+func TestDoURLReplacements(t *testing.T) {
+	novelConfig := readTestConfiguration(t, `
+replacements:
+  - from-regex: ^https://old\.example\.com/(.+)$
+    to: https://example.com/$1
+  - url: https://old.example.com/chapter-2
+    replacement: https://example.com/chapter-2-fixed
+`)
+	tests := []struct {
+		url             string
+		expectedURL     string
+		expectedChanged bool
+	}{
+		{"https://old.example.com/chapter-1", "https://example.com/chapter-1", true},
+		// exact URL replacements are preferred over regular expression replacements
+		{"https://old.example.com/chapter-2", "https://example.com/chapter-2-fixed", true},
+		{"https://example.com/chapter-3", "https://example.com/chapter-3", false},
+		{"://invalid", "://invalid", false},
+	}
+	for _, test := range tests {
+		actualURL, actualChanged := novelConfig.DoURLReplacements(test.url)
+		if actualURL != test.expectedURL || actualChanged != test.expectedChanged {
+			t.Errorf(
+				"DoURLReplacements(%q) = (%q, %t), expected (%q, %t)",
+				test.url, actualURL, actualChanged, test.expectedURL, test.expectedChanged,
+			)
+		}
+	}
+
+	applied := novelConfig.AppliedReplacements()
+	if len(applied) != 2 {
+		t.Fatalf("expected 2 applied replacements, got %d", len(applied))
+	}
+	if applied[0].MatchedRule != `^https://old\.example\.com/(.+)$` {
+		t.Errorf("unexpected matched rule %q", applied[0].MatchedRule)
+	}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Transform is a single step of the content transformation pipeline, every step uses exactly one option
+type Transform struct {
+	Remove          string              `yaml:"remove"`
+	Unwrap          string              `yaml:"unwrap"`
+	Keep            string              `yaml:"keep"`
+	Rename          *RenameTransform    `yaml:"rename"`
+	Replace         *ReplaceTransform   `yaml:"replace"`
+	SetAttribute    *AttributeTransform `yaml:"set-attribute"`
+	RemoveAttribute *AttributeTransform `yaml:"remove-attribute"`
+}
+
+// RenameTransform renames the tag of all elements matching the selector
+type RenameTransform struct {
+	Selector string `yaml:"selector"`
+	Tag      string `yaml:"tag"`
+}
+
+// ReplaceTransform replaces all matches of the regular expression with the replacement string
+// capture groups can be used in the replacement with $1 or ${name}
+type ReplaceTransform struct {
+	Regex string `yaml:"regex"`
+	With  string `yaml:"with"`
+	// compiled pattern of the regex option
+	pattern *regexp.Regexp
+}
+
+// AttributeTransform sets or removes the attribute of all elements matching the selector
+type AttributeTransform struct {
+	Selector string `yaml:"selector"`
+	Name     string `yaml:"name"`
+	Value    string `yaml:"value"`
+}
+
+// validate checks that exactly one option is set and that the option contains all required values
+// the regular expression of the replace option is compiled separately
+func (t *Transform) validate() error {
+	setOptions := 0
+	for _, isSet := range []bool{
+		t.Remove != "", t.Unwrap != "", t.Keep != "",
+		t.Rename != nil, t.Replace != nil, t.SetAttribute != nil, t.RemoveAttribute != nil,
+	} {
+		if isSet {
+			setOptions++
+		}
+	}
+	if setOptions != 1 {
+		return fmt.Errorf(
+			"transforms require exactly one of remove, unwrap, keep, rename, replace, set-attribute or remove-attribute",
+		)
+	}
+
+	switch {
+	case t.Rename != nil && (t.Rename.Selector == "" || t.Rename.Tag == ""):
+		return fmt.Errorf("rename transforms require a selector and a tag")
+	case t.SetAttribute != nil && (t.SetAttribute.Selector == "" || t.SetAttribute.Name == ""):
+		return fmt.Errorf("set-attribute transforms require a selector and a name")
+	case t.RemoveAttribute != nil && (t.RemoveAttribute.Selector == "" || t.RemoveAttribute.Name == ""):
+		return fmt.Errorf("remove-attribute transforms require a selector and a name")
+	}
+	return nil
+}
+
+// compile compiles the regular expression of the replace transform
+func (r *ReplaceTransform) compile() (err error) {
+	if r.Regex == "" {
+		return fmt.Errorf("replace transforms require a regex")
+	}
+	r.pattern, err = compileRegex(r.Regex)
+	return err
+}
+
+// Pattern returns the compiled regular expression of the replace transform
+func (r *ReplaceTransform) Pattern() *regexp.Regexp {
+	return r.pattern
+}
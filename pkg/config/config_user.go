@@ -0,0 +1,51 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// directory of the user configuration file in the configuration directory of the user
+const userConfigDirectoryName = "epub-scraper"
+
+// UserConfig contains the settings of the user configuration file, which apply to every run
+type UserConfig struct {
+	Telemetry Telemetry `yaml:"telemetry"`
+}
+
+// Telemetry contains the options of the opt-in error reporting to Sentry
+type Telemetry struct {
+	Enabled bool `yaml:"enabled"`
+	// DSN of an own Sentry instance, the DSN of the project is used if empty
+	DSN string `yaml:"dsn"`
+}
+
+// GetUserConfigurationPath returns the path of the user configuration file in the configuration directory of the user
+// f.e. ~/.config/epub-scraper/config.yaml on Linux or %AppData%\epub-scraper\config.yaml on Windows
+func GetUserConfigurationPath() (string, error) {
+	userConfigDirectory, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userConfigDirectory, userConfigDirectoryName, "config.yaml"), nil
+}
+
+// ReadUserConfigurationFile reads the passed user configuration file
+// the default configuration with disabled telemetry is returned if the file doesn't exist
+func (p *Parser) ReadUserConfigurationFile(fileName string) (*UserConfig, error) {
+	userConfig := &UserConfig{}
+	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+	if os.IsNotExist(err) {
+		return userConfig, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(content, userConfig); err != nil {
+		return nil, err
+	}
+	return userConfig, nil
+}
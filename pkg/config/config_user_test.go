@@ -0,0 +1,24 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReadUserConfigurationFile(t *testing.T) {
+	p := NewParser()
+	userConfig, err := p.ReadUserConfigurationFile(filepath.Join(t.TempDir(), "config.yaml"))
+	if err != nil || userConfig.Telemetry.Enabled {
+		t.Errorf("expected disabled telemetry without user configuration file, got %+v and %v", userConfig, err)
+	}
+
+	fileName := writeTestConfiguration(t, "telemetry:\n  enabled: true\n  dsn: https://key@sentry.example.com/1\n")
+	userConfig, err = p.ReadUserConfigurationFile(fileName)
+	if err != nil || !userConfig.Telemetry.Enabled || userConfig.Telemetry.DSN != "https://key@sentry.example.com/1" {
+		t.Errorf("expected enabled telemetry with own DSN, got %+v and %v", userConfig, err)
+	}
+
+	if _, err = p.ReadUserConfigurationFile(writeTestConfiguration(t, "telemetry: [")); err == nil {
+		t.Error("expected error for invalid user configuration file")
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+import "regexp"
+
+// Volumes contains the options to group the chapters into volumes based on the extracted chapter titles
+// the volume title is the named capture group "Volume" if defined, else the whole match of the regular expression
+type Volumes struct {
+	TitleRegex string `yaml:"title-regex"`
+	// compiled pattern of the title-regex option
+	titlePattern *regexp.Regexp
+}
+
+// compile compiles the title regex of the volume detection
+func (v *Volumes) compile() (err error) {
+	v.titlePattern = nil
+	if v.TitleRegex != "" {
+		v.titlePattern, err = compileRegex(v.TitleRegex)
+	}
+	return err
+}
+
+// DetectVolume returns the volume title detected from the passed chapter title
+// returns an empty string if no title regex is configured or the chapter title doesn't match
+func (v *Volumes) DetectVolume(chapterTitle string) string {
+	if v.titlePattern == nil {
+		return ""
+	}
+	matches := v.titlePattern.FindStringSubmatch(chapterTitle)
+	if matches == nil {
+		return ""
+	}
+	if index := v.titlePattern.SubexpIndex("Volume"); index > 0 {
+		return matches[index]
+	}
+	return matches[0]
+}
@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestDetectVolume(t *testing.T) {
+	tests := []struct {
+		titleRegex string
+		title      string
+		expected   string
+	}{
+		{`^(?P<Volume>Volume \d+)`, "Volume 2 Chapter 13 - Departure", "Volume 2"},
+		{`^Volume \d+`, "Volume 2 Chapter 13 - Departure", "Volume 2"},
+		{`^(?P<Volume>Volume \d+)`, "Chapter 13 - Departure", ""},
+		{"", "Volume 2 Chapter 13 - Departure", ""},
+	}
+	for _, test := range tests {
+		volumes := &Volumes{TitleRegex: test.titleRegex}
+		if err := volumes.compile(); err != nil {
+			t.Fatal(err)
+		}
+		if actual := volumes.DetectVolume(test.title); actual != test.expected {
+			t.Errorf("volume of %q with regex %q is %q, expected %q", test.title, test.titleRegex, actual, test.expected)
+		}
+	}
+}
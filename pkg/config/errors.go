@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// RegexError is returned if a configured regular expression can't be compiled
+type RegexError struct {
+	Pattern string
+	Err     error
+}
+
+// Error returns the error message including the invalid pattern
+func (e *RegexError) Error() string {
+	return fmt.Sprintf("invalid regular expression %q: %s", e.Pattern, e.Err)
+}
+
+// Unwrap returns the original error of the regexp package
+func (e *RegexError) Unwrap() error {
+	return e.Err
+}
+
+// compileRegex compiles the passed regular expression and wraps occurring errors into a RegexError
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, &RegexError{Pattern: pattern, Err: err}
+	}
+	return re, nil
+}
+
+// SelectorError is returned if a configured CSS selector is invalid or doesn't select the required elements
+type SelectorError struct {
+	Selector string
+	// URL of the page the selector got used on, empty for invalid selectors
+	URL string
+	Err error
+}
+
+// Error returns the error message including the selector and the page URL
+func (e *SelectorError) Error() string {
+	if e.URL == "" {
+		return fmt.Sprintf("invalid selector %q: %s", e.Selector, e.Err)
+	}
+	return fmt.Sprintf("selector %q on %s: %s", e.Selector, e.URL, e.Err)
+}
+
+// Unwrap returns the original error
+func (e *SelectorError) Unwrap() error {
+	return e.Err
+}
+
+// EntryError is returned if a single entry of the configuration is invalid
+type EntryError struct {
+	// file the entry got defined in, empty for entries of the novel configuration itself
+	File string
+	// dotted key path of the entry, f.e. "blacklist.2" or "sites.0.backoff"
+	Path string
+	Err  error
+}
+
+// Error returns the error message including the key path and the file of the entry
+func (e *EntryError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("%s: %s", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.File, e.Path, e.Err)
+}
+
+// Unwrap returns the original error
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
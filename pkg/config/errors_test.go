@@ -0,0 +1,55 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEntryError(t *testing.T) {
+	_, err := NewParser().ReadConfigurationFile(writeTestConfiguration(t, `
+blacklist:
+  - https://www.example.com/teaser
+  - regex: "([a-"
+`))
+	var (
+		entryError *EntryError
+		regexError *RegexError
+	)
+	if !errors.As(err, &entryError) || entryError.Path != "blacklist.1" || entryError.File != "" {
+		t.Fatalf("expected entry error of the second blacklist entry, got %v", err)
+	}
+	if !errors.As(err, &regexError) || regexError.Pattern != "([a-" {
+		t.Fatalf("expected regex error of the invalid pattern, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), `blacklist.1: invalid regular expression "([a-"`) {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+
+	entryError.File = "sites.yaml"
+	if !strings.HasPrefix(entryError.Error(), "sites.yaml: blacklist.1: ") {
+		t.Errorf("expected error message with the file of the entry, got %q", entryError.Error())
+	}
+}
+
+func TestSelectorError(t *testing.T) {
+	cause := errors.New("no link selected")
+	tests := []struct {
+		err      *SelectorError
+		expected string
+	}{
+		{&SelectorError{Selector: "div[[", Err: cause}, `invalid selector "div[[": no link selected`},
+		{
+			&SelectorError{Selector: "a.next", URL: "https://www.example.com/toc", Err: cause},
+			`selector "a.next" on https://www.example.com/toc: no link selected`,
+		},
+	}
+	for _, test := range tests {
+		if test.err.Error() != test.expected {
+			t.Errorf("expected error message %q, got %q", test.expected, test.err.Error())
+		}
+		if !errors.Is(test.err, cause) {
+			t.Errorf("expected %v to unwrap to the original error", test.err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+)
+
+// isGlobPattern checks if the passed string contains any glob wildcards
+func isGlobPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?")
+}
+
+// compileGlob converts the passed glob pattern into an anchored case-insensitive regular expression
+// "*" matches any sequence of characters and "?" matches any single character
+func compileGlob(pattern string) *regexp.Regexp {
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.ReplaceAll(expression, `\*`, ".*")
+	expression = strings.ReplaceAll(expression, `\?`, ".")
+	return regexp.MustCompile("(?i)^" + expression + "$")
+}
@@ -0,0 +1,45 @@
+package config
+
+import "testing"
+
+func TestIsGlobPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected bool
+	}{
+		{"www.example.com", false},
+		{"*.blogspot.com", true},
+		{"https://example.com/chapter-?", true},
+		{"", false},
+	}
+	for _, test := range tests {
+		if actual := isGlobPattern(test.pattern); actual != test.expected {
+			t.Errorf("isGlobPattern(%q) = %t, expected %t", test.pattern, actual, test.expected)
+		}
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		subject  string
+		expected bool
+	}{
+		{"*.blogspot.com", "novel.blogspot.com", true},
+		{"*.blogspot.com", "NOVEL.BlogSpot.com", true},
+		{"*.blogspot.com", "blogspot.com", false},
+		{"*.blogspot.com", "novel.blogspot.com.evil.org", false},
+		{"https://example.com/chapter-?", "https://example.com/chapter-1", true},
+		{"https://example.com/chapter-?", "https://example.com/chapter-10", false},
+		{"https://example.com/*/teaser", "https://example.com/novel/chapter-1/teaser", true},
+		// regular expression meta characters are matched literally
+		{"https://example.com/?p=1", "https://example.com/?p=1", true},
+		{"https://example.com/chapter(1)", "https://example.com/chapter(1)", true},
+		{"https://example.com/chapter.1", "https://example.com/chapterX1", false},
+	}
+	for _, test := range tests {
+		if actual := compileGlob(test.pattern).MatchString(test.subject); actual != test.expected {
+			t.Errorf("glob %q matching %q = %t, expected %t", test.pattern, test.subject, actual, test.expected)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// includedSites contains the state of the include resolution of a single novel configuration
+type includedSites struct {
+	sites  []SiteConfiguration
+	origin map[string]string
+	loaded map[string]bool
+}
+
+// resolveIncludes loads the site configurations of all included files and directories
+// and appends them to the site configurations of the novel configuration
+// site configurations of the novel configuration override included site configurations host by host
+func (p *Parser) resolveIncludes(novelConfig *NovelConfig, fileName string) error {
+	for i := range novelConfig.Sites {
+		novelConfig.Sites[i].index = i
+		p.resolveSitePaths(&novelConfig.Sites[i], novelConfig.BaseDirectory)
+	}
+	if len(novelConfig.Include) == 0 {
+		return nil
+	}
+
+	novelFile, err := filepath.Abs(fileName)
+	if err != nil {
+		return err
+	}
+
+	included := &includedSites{
+		origin: make(map[string]string),
+		loaded: make(map[string]bool),
+	}
+	for _, include := range novelConfig.Include {
+		if err = p.includePath(p.resolvePath(novelConfig.BaseDirectory, include), []string{novelFile}, included); err != nil {
+			return err
+		}
+	}
+
+	for _, site := range included.sites {
+		if novelConfig.hasSiteConfiguration(site.Pattern()) {
+			continue
+		}
+		novelConfig.Sites = append(novelConfig.Sites, site)
+	}
+	return nil
+}
+
+// includePath includes the passed file or all YAML files of the passed directory
+func (p *Parser) includePath(path string, chain []string, included *includedSites) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to include %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return p.includeFile(path, chain, included)
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return fmt.Errorf("unable to include %s: %w", path, err)
+	}
+	var fileNames []string
+	for _, file := range files {
+		if !file.IsDir() && (strings.HasSuffix(file.Name(), ".yaml") || strings.HasSuffix(file.Name(), ".yml")) {
+			fileNames = append(fileNames, filepath.Join(path, file.Name()))
+		}
+	}
+	sort.Strings(fileNames)
+	for _, fileName := range fileNames {
+		if err = p.includeFile(fileName, chain, included); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// includeFile reads the passed site library, resolves the includes of the library and adds the site configurations
+func (p *Parser) includeFile(fileName string, chain []string, included *includedSites) error {
+	for _, chainedFile := range chain {
+		if chainedFile == fileName {
+			return fmt.Errorf("include cycle detected: %s", strings.Join(append(chain, fileName), " -> "))
+		}
+	}
+	// the same library can be included multiple times through different paths without conflicting with itself
+	if included.loaded[fileName] {
+		return nil
+	}
+	included.loaded[fileName] = true
+
+	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		return fmt.Errorf("unable to include %s: %w", fileName, err)
+	}
+	var library SiteLibrary
+	if err = yaml.Unmarshal(content, &library); err != nil {
+		return fmt.Errorf("unable to parse included file %s: %w", fileName, err)
+	}
+
+	chain = append(chain[:len(chain):len(chain)], fileName)
+	for _, include := range library.Include {
+		if err = p.includePath(p.resolvePath(filepath.Dir(fileName), include), chain, included); err != nil {
+			return err
+		}
+	}
+
+	for i, site := range library.Sites {
+		if origin, exists := included.origin[site.Pattern()]; exists {
+			return fmt.Errorf("site configuration for %s is defined in %s and %s", site.Pattern(), origin, fileName)
+		}
+		included.origin[site.Pattern()] = fileName
+		site.definedIn = fileName
+		site.index = i
+		p.resolveSitePaths(&site, filepath.Dir(fileName))
+		included.sites = append(included.sites, site)
+	}
+	return nil
+}
+
+// resolvePath returns the passed path as absolute path, relative paths are resolved against the base directory
+func (p *Parser) resolvePath(baseDirectory string, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(baseDirectory, path)
+}
+
+// hasSiteConfiguration checks if the novel configuration itself already contains a site configuration
+// with the same host and path pattern
+func (s *NovelConfig) hasSiteConfiguration(pattern string) bool {
+	for _, site := range s.Sites {
+		if site.definedIn == "" && site.Pattern() == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSitePaths resolves the relative file paths of the passed site configuration against the base directory
+func (p *Parser) resolveSitePaths(site *SiteConfiguration, baseDirectory string) {
+	if site.HTTP.CABundle != "" {
+		site.HTTP.CABundle = p.resolvePath(baseDirectory, site.HTTP.CABundle)
+	}
+}
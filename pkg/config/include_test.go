@@ -0,0 +1,137 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFiles writes the passed files relative to the passed directory
+func writeTestFiles(t *testing.T, directory string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		fileName := filepath.Join(directory, name)
+		if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fileName, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolveIncludes(t *testing.T) {
+	directory := t.TempDir()
+	writeTestFiles(t, directory, map[string]string{
+		"novel.yaml": `
+include:
+  - sites
+sites:
+  - host: www.example.com
+    chapter-content:
+      content-selector: div.novel
+chapters:
+  - chapter:
+      url: https://www.example.com/chapter-1
+  - chapter:
+      url: https://blog.example.org/chapter-2
+`,
+		"sites/a.yaml": `
+include:
+  - ../shared/translators.yaml
+sites:
+  - host: www.example.com
+    chapter-content:
+      content-selector: div.library
+`,
+		"shared/translators.yaml": `
+sites:
+  - host: blog.example.org
+    chapter-content:
+      content-selector: div.entry-content
+`,
+	})
+
+	novelConfig, err := NewParser().ReadConfigurationFile(filepath.Join(directory, "novel.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(novelConfig.Sites) != 2 {
+		t.Fatalf("expected the own and the nested included site configuration, got %d sites", len(novelConfig.Sites))
+	}
+	// site configurations of the novel configuration override included site configurations
+	for i, expected := range []string{"div.novel", "div.entry-content"} {
+		contentSelector := novelConfig.Chapters[i].Chapter.ContentSelector
+		if contentSelector == nil || *contentSelector != expected {
+			t.Errorf("expected content selector %s for chapter %d, got %v", expected, i+1, contentSelector)
+		}
+	}
+}
+
+func TestResolveIncludesErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         map[string]string
+		expectedError string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"novel.yaml": "include:\n  - a.yaml\n",
+				"a.yaml":     "include:\n  - b.yaml\n",
+				"b.yaml":     "include:\n  - a.yaml\n",
+			},
+			expectedError: "include cycle detected",
+		},
+		{
+			name: "self include",
+			files: map[string]string{
+				"novel.yaml": "include:\n  - novel.yaml\n",
+			},
+			expectedError: "include cycle detected",
+		},
+		{
+			name: "duplicate host",
+			files: map[string]string{
+				"novel.yaml": "include:\n  - a.yaml\n  - b.yaml\n",
+				"a.yaml":     "sites:\n  - host: www.example.com\n",
+				"b.yaml":     "sites:\n  - host: www.example.com\n",
+			},
+			expectedError: "site configuration for www.example.com is defined in",
+		},
+		{
+			name: "missing file",
+			files: map[string]string{
+				"novel.yaml": "include:\n  - missing.yaml\n",
+			},
+			expectedError: "unable to include",
+		},
+	}
+
+	for _, test := range tests {
+		directory := t.TempDir()
+		writeTestFiles(t, directory, test.files)
+		_, err := NewParser().ReadConfigurationFile(filepath.Join(directory, "novel.yaml"))
+		if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+			t.Errorf("%s: expected error containing %q, got %v", test.name, test.expectedError, err)
+		}
+	}
+}
+
+func TestValidateIncludedFile(t *testing.T) {
+	directory := t.TempDir()
+	writeTestFiles(t, directory, map[string]string{
+		"novel.yaml": "include:\n  - sites.yaml\n",
+		"sites.yaml": "sites:\n  - host: www.example.com\n    chapter-content:\n      content-selector: \"div[[\"\n",
+	})
+
+	issues, err := NewParser().ValidateConfigurationFile(filepath.Join(directory, "novel.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].File != filepath.Join(directory, "sites.yaml") || issues[0].Line != 4 {
+		t.Errorf("expected issue in line 4 of the included file, got %v", issues)
+	}
+}
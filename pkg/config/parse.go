@@ -5,7 +5,6 @@ import (
 	"net/url"
 	"path/filepath"
 
-	"github.com/DaRealFreak/epub-scraper/pkg/raven"
 	"gopkg.in/yaml.v2"
 )
 
@@ -18,29 +17,100 @@ func NewParser() *Parser {
 }
 
 // ReadConfigurationFile tries to read the passed configuration file and parse it into a NovelConfig struct
-func (p *Parser) ReadConfigurationFile(fileName string) (novelConfig *NovelConfig, err error) {
-	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+// returns an EntryError for the first invalid entry of the configuration
+func (p *Parser) ReadConfigurationFile(fileName string) (*NovelConfig, error) {
+	novelConfig, entryErrors, err := p.readConfigurationFile(fileName)
 	if err != nil {
 		return nil, err
 	}
+	if len(entryErrors) > 0 {
+		return nil, entryErrors[0]
+	}
+	return novelConfig, nil
+}
+
+// readConfigurationFile reads and prepares the passed configuration file
+// invalid entries don't abort the preparation and are returned together with the configuration
+func (p *Parser) readConfigurationFile(
+	fileName string,
+) (novelConfig *NovelConfig, entryErrors []*EntryError, err error) {
+	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		return nil, nil, err
+	}
 	err = yaml.Unmarshal(content, &novelConfig)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if novelConfig == nil {
+		novelConfig = &NovelConfig{}
 	}
 	// set base directory for includes and the like
-	baseDirectory := filepath.Dir(fileName)
-	novelConfig.BaseDirectory, err = filepath.Abs(baseDirectory)
-	p.mergeSourceConfigSiteConfig(novelConfig)
-	return novelConfig, err
+	novelConfig.BaseDirectory, err = filepath.Abs(filepath.Dir(fileName))
+	if err != nil {
+		return nil, nil, err
+	}
+	if entryErrors, err = p.prepare(novelConfig, fileName); err != nil {
+		return nil, nil, err
+	}
+	return novelConfig, entryErrors, nil
+}
+
+// PrepareConfiguration prepares a novel configuration created in Go the same way as a read configuration file,
+// relative paths are resolved against the base directory or the working directory if no base directory is set,
+// includes are resolved, all patterns are compiled and the site configurations are merged into the chapter sources
+// already prepared configurations are not changed
+func (p *Parser) PrepareConfiguration(novelConfig *NovelConfig) (err error) {
+	if novelConfig.prepared {
+		return nil
+	}
+	if novelConfig.BaseDirectory == "" {
+		if novelConfig.BaseDirectory, err = filepath.Abs("."); err != nil {
+			return err
+		}
+	}
+	entryErrors, err := p.prepare(novelConfig, "")
+	if err != nil {
+		return err
+	}
+	if len(entryErrors) > 0 {
+		return entryErrors[0]
+	}
+	return nil
+}
+
+// prepare resolves the relative paths and includes, compiles the patterns and merges the site configurations
+// returns the problems of all invalid entries, the configuration is only marked as prepared without invalid entries
+func (p *Parser) prepare(novelConfig *NovelConfig, fileName string) (entryErrors []*EntryError, err error) {
+	if novelConfig.Cookies.File != "" {
+		novelConfig.Cookies.File = p.resolvePath(novelConfig.BaseDirectory, novelConfig.Cookies.File)
+	}
+	if novelConfig.Cache.Directory != "" {
+		novelConfig.Cache.Directory = p.resolvePath(novelConfig.BaseDirectory, novelConfig.Cache.Directory)
+	}
+	if novelConfig.Emojis.Directory != "" {
+		novelConfig.Emojis.Directory = p.resolvePath(novelConfig.BaseDirectory, novelConfig.Emojis.Directory)
+	}
+	if err = p.resolveIncludes(novelConfig, fileName); err != nil {
+		return nil, err
+	}
+	entryErrors = novelConfig.compileEntries()
+	if err = p.mergeSourceConfigSiteConfig(novelConfig); err != nil {
+		return nil, err
+	}
+	novelConfig.prepared = len(entryErrors) == 0
+	return entryErrors, nil
 }
 
 // mergeSourceConfigSiteConfig merges the chapter configuration with the site configuration
 // or sets the default values in case neither the chapter nor the site configuration has a value set
-func (p *Parser) mergeSourceConfigSiteConfig(novelConfig *NovelConfig) {
+func (p *Parser) mergeSourceConfigSiteConfig(novelConfig *NovelConfig) error {
 	for _, source := range novelConfig.Chapters {
 		if source.Toc != nil {
 			tocURL, err := url.Parse(source.Toc.URL)
-			raven.CheckError(err)
+			if err != nil {
+				return err
+			}
 			site := novelConfig.GetSiteConfigFromURL(tocURL)
 			p.updatePagination(&source.Toc.Pagination, &site.Pagination)
 			p.updateTitleContent(&source.Toc.TitleContent, &site.TitleContent)
@@ -48,12 +118,15 @@ func (p *Parser) mergeSourceConfigSiteConfig(novelConfig *NovelConfig) {
 		}
 		if source.Chapter != nil {
 			tocURL, err := url.Parse(source.Chapter.URL)
-			raven.CheckError(err)
+			if err != nil {
+				return err
+			}
 			site := novelConfig.GetSiteConfigFromURL(tocURL)
 			p.updateTitleContent(&source.Chapter.TitleContent, &site.TitleContent)
 			p.updateChapterContent(&source.Chapter.ChapterContent, &site.ChapterContent)
 		}
 	}
+	return nil
 }
 
 // updatePagination updates specifically the Pagination struct of the chapter/site configuration
@@ -103,6 +176,13 @@ func (p *Parser) updateChapterContent(sourceConfig *ChapterContent, siteConfig *
 	if sourceConfig.CleanupOptions.CleanupRegex == "" && siteConfig.CleanupOptions.CleanupRegex != "" {
 		sourceConfig.CleanupOptions.CleanupRegex = siteConfig.CleanupOptions.CleanupRegex
 	}
+	if sourceConfig.CleanupOptions.Transforms == nil {
+		if siteConfig.CleanupOptions.Transforms == nil {
+			var siteConfigDefault []Transform
+			siteConfig.CleanupOptions.Transforms = &siteConfigDefault
+		}
+		sourceConfig.CleanupOptions.Transforms = siteConfig.CleanupOptions.Transforms
+	}
 }
 
 func (p *Parser) updateTitleContent(sourceConfig *TitleContent, siteConfig *TitleContent) {
@@ -140,4 +220,11 @@ func (p *Parser) updateTitleContent(sourceConfig *TitleContent, siteConfig *Titl
 	if sourceConfig.CleanupOptions.CleanupRegex == "" && siteConfig.CleanupOptions.CleanupRegex != "" {
 		sourceConfig.CleanupOptions.CleanupRegex = siteConfig.CleanupOptions.CleanupRegex
 	}
+	if sourceConfig.CleanupOptions.Transforms == nil {
+		if siteConfig.CleanupOptions.Transforms == nil {
+			var siteConfigDefault []Transform
+			siteConfig.CleanupOptions.Transforms = &siteConfigDefault
+		}
+		sourceConfig.CleanupOptions.Transforms = siteConfig.CleanupOptions.Transforms
+	}
 }
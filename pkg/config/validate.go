@@ -0,0 +1,452 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// replacementGroupPattern matches the capture group references "$1", "${1}" and "${name}" of a regex replacement
+var replacementGroupPattern = regexp.MustCompile(`\$(\{\w+\}|\w+)`)
+
+// ValidationIssue contains a single problem found during the validation of a configuration file
+type ValidationIssue struct {
+	File    string
+	Line    int
+	Message string
+}
+
+// String returns the issue in the common "file:line: message" format
+func (i *ValidationIssue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.File, i.Message)
+}
+
+// validator contains the YAML node index of a single file used to resolve line numbers
+// validators of included files share the issues and the validators of the main configuration file
+type validator struct {
+	fileName string
+	nodes    map[string]*yamlv3.Node
+	// value nodes of the key paths to resolve the line of invalid values
+	values   map[string]*yamlv3.Node
+	issues   *[]*ValidationIssue
+	included map[string]*validator
+}
+
+// ValidateConfigurationFile reads the passed configuration file and checks all regular expressions,
+// CSS selectors and URLs without accessing the network
+// an error is only returned if the file itself couldn't be read
+func (p *Parser) ValidateConfigurationFile(fileName string) (issues []*ValidationIssue, err error) {
+	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	v := &validator{
+		fileName: fileName,
+		nodes:    make(map[string]*yamlv3.Node),
+		values:   make(map[string]*yamlv3.Node),
+		issues:   &issues,
+		included: make(map[string]*validator),
+	}
+	if err = v.parseNodes(content); err != nil {
+		v.addIssue(0, err.Error())
+		return issues, nil
+	}
+
+	novelConfig, entryErrors, err := p.readConfigurationFile(fileName)
+	if err != nil {
+		v.addIssue(v.lineOfError("", err), err.Error())
+		return issues, nil
+	}
+	for _, entryError := range entryErrors {
+		v.forFile(entryError.File).addEntryIssue(entryError)
+	}
+
+	v.validateSites(novelConfig)
+	v.validateChapters(novelConfig)
+	for i, entry := range novelConfig.BackList {
+		// invalid patterns are already reported as invalid entries
+		if entry.URL != "" && !entry.IsPattern() {
+			path := fmt.Sprintf("blacklist.%d", i)
+			if v.isDefined(path + ".url") {
+				path += ".url"
+			}
+			v.checkURL(path, entry.URL)
+		}
+	}
+	for i, replacement := range novelConfig.Replacements {
+		path := fmt.Sprintf("replacements.%d", i)
+		switch {
+		case replacement.FromRegex == "":
+			v.checkURL(path+".url", replacement.Url)
+			v.checkURL(path+".replacement", replacement.ReplacementURL)
+		case replacement.fromPattern == nil:
+			// invalid patterns are already reported as invalid entries
+		case strings.Contains(replacement.To, "$"):
+			v.checkReplacementGroups(path+".to", replacement.fromPattern, replacement.To)
+		default:
+			v.checkURL(path+".to", replacement.To)
+		}
+	}
+	v.checkURL("emojis.image-url", novelConfig.Emojis.ImageURL)
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].File != issues[j].File {
+			return issues[i].File < issues[j].File
+		}
+		return issues[i].Line < issues[j].Line
+	})
+	return issues, nil
+}
+
+// parseNodes parses the passed YAML content and indexes all nodes
+func (v *validator) parseNodes(content []byte) error {
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &document); err != nil {
+		return err
+	}
+	if len(document.Content) > 0 {
+		v.indexNodes(document.Content[0], "")
+	}
+	return nil
+}
+
+// forFile returns the validator of the passed included file, the main validator is returned for an empty file name
+func (v *validator) forFile(fileName string) *validator {
+	if fileName == "" {
+		return v
+	}
+	if fileValidator, ok := v.included[fileName]; ok {
+		return fileValidator
+	}
+	fileValidator := &validator{
+		fileName: fileName,
+		nodes:    make(map[string]*yamlv3.Node),
+		values:   make(map[string]*yamlv3.Node),
+		issues:   v.issues,
+		included: v.included,
+	}
+	// the included file was already parsed successfully during reading the configuration file
+	if content, err := ioutil.ReadFile(filepath.Clean(fileName)); err == nil {
+		_ = fileValidator.parseNodes(content)
+	}
+	v.included[fileName] = fileValidator
+	return fileValidator
+}
+
+// indexNodes walks through the YAML node tree and saves every node under its dotted key path
+func (v *validator) indexNodes(node *yamlv3.Node, path string) {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			childPath := node.Content[i].Value
+			if path != "" {
+				childPath = path + "." + childPath
+			}
+			// use the key node for the line since block sequences start on the line after the key
+			v.nodes[childPath] = node.Content[i]
+			v.values[childPath] = node.Content[i+1]
+			v.indexNodes(node.Content[i+1], childPath)
+		}
+	case yamlv3.SequenceNode:
+		for i, child := range node.Content {
+			childPath := path + "." + strconv.Itoa(i)
+			v.nodes[childPath] = child
+			v.values[childPath] = child
+			v.indexNodes(child, childPath)
+		}
+	case yamlv3.AliasNode:
+		if node.Alias != nil {
+			v.indexNodes(node.Alias, path)
+		}
+	}
+}
+
+// lineOfPath returns the line of the passed key path or 0 if the key is not defined in the file
+func (v *validator) lineOfPath(path string) int {
+	if node, ok := v.nodes[path]; ok {
+		return node.Line
+	}
+	return 0
+}
+
+// lineOfValue returns the line of the first scalar value with the passed value or 0 if no value matches
+// only values of the passed key path and its children are checked if the passed key path is not empty
+func (v *validator) lineOfValue(path string, value string) (line int) {
+	for valuePath, node := range v.values {
+		if path != "" && valuePath != path && !strings.HasPrefix(valuePath, path+".") {
+			continue
+		}
+		// prefer the first line since the map is not ordered
+		if node.Kind == yamlv3.ScalarNode && node.Value == value && (line == 0 || node.Line < line) {
+			line = node.Line
+		}
+	}
+	return line
+}
+
+// lineOfError tries to resolve the line of the value causing the passed error in the passed key path
+func (v *validator) lineOfError(path string, err error) int {
+	var (
+		urlError   *url.Error
+		regexError *RegexError
+	)
+	switch {
+	case errors.As(err, &urlError):
+		return v.lineOfValue(path, urlError.URL)
+	case errors.As(err, &regexError):
+		return v.lineOfValue(path, regexError.Pattern)
+	default:
+		return 0
+	}
+}
+
+// addEntryIssue adds a new issue for the passed invalid entry
+// the line of an invalid pattern is preferred over the line of the entry containing the pattern
+func (v *validator) addEntryIssue(entryError *EntryError) {
+	line := v.lineOfError(entryError.Path, entryError.Err)
+	if line == 0 {
+		line = v.lineOfPath(entryError.Path)
+	}
+	v.addIssue(line, "%s", entryError.Err)
+}
+
+// addIssue adds a new issue for the passed line
+func (v *validator) addIssue(line int, format string, args ...interface{}) {
+	*v.issues = append(*v.issues, &ValidationIssue{
+		File:    v.fileName,
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// isDefined checks if the key path is defined in the file
+// merged values from the site configuration are not defined in the source and only get validated in the site
+func (v *validator) isDefined(path string) bool {
+	_, ok := v.nodes[path]
+	return ok
+}
+
+// validateSites validates all site configurations in the file they got defined in
+func (v *validator) validateSites(novelConfig *NovelConfig) {
+	for i := range novelConfig.Sites {
+		site := &novelConfig.Sites[i]
+		v.forFile(site.definedIn).validateSite(fmt.Sprintf("sites.%d", site.index), site)
+	}
+}
+
+// validateSite validates a single site configuration
+func (v *validator) validateSite(path string, site *SiteConfiguration) {
+	if site.Host == "" && site.HostRegex == "" {
+		v.addIssue(v.lineOfPath(path), "site configuration has neither host nor host-regex")
+	}
+	for j, redirect := range site.Redirects {
+		v.checkSelector(fmt.Sprintf("%s.redirects.%d", path, j), redirect)
+	}
+	if site.HTTP.CABundle != "" {
+		if _, err := os.Stat(site.HTTP.CABundle); err != nil {
+			v.addIssue(v.lineOfPath(path+".http.ca-bundle"), "unable to read CA bundle: %s", err)
+		}
+	}
+	if site.Login != nil {
+		v.validateLogin(path+".login", site.Login)
+	}
+	v.validatePagination(path+".pagination", &site.Pagination)
+	v.validateTitleContent(path+".title-content", &site.TitleContent)
+	v.validateChapterContent(path+".chapter-content", &site.ChapterContent)
+}
+
+// validateLogin validates the login flow of a site configuration
+func (v *validator) validateLogin(path string, login *Login) {
+	if login.URL == "" {
+		v.addIssue(v.lineOfPath(path), "login requires an url")
+	}
+	v.checkURL(path+".url", login.URL)
+	if login.UsernameField == "" || login.PasswordField == "" {
+		v.addIssue(v.lineOfPath(path), "login requires a username-field and a password-field")
+	}
+	if login.UsernameEnv == "" || login.PasswordEnv == "" {
+		v.addIssue(v.lineOfPath(path), "login requires a username-env and a password-env")
+	}
+	v.checkSelector(path+".csrf-selector", login.CSRFSelector)
+	v.checkSelector(path+".logged-in-selector", login.LoggedInSelector)
+}
+
+// validateChapters validates all chapter sources
+func (v *validator) validateChapters(novelConfig *NovelConfig) {
+	for i, source := range novelConfig.Chapters {
+		path := fmt.Sprintf("chapters.%d", i)
+		switch {
+		case source.Toc != nil && source.Chapter != nil:
+			v.addIssue(v.lineOfPath(path), "chapter source can't contain toc and chapter at the same time")
+		case source.Toc == nil && source.Chapter == nil:
+			v.addIssue(v.lineOfPath(path), "chapter source requires either toc or chapter")
+		}
+		if source.Toc != nil {
+			tocPath := path + ".toc"
+			if source.Toc.URL == "" {
+				v.addIssue(v.lineOfPath(tocPath), "toc requires an url")
+			}
+			v.checkURL(tocPath+".url", source.Toc.URL)
+			if source.Toc.ChapterSelector == "" {
+				v.addIssue(v.lineOfPath(tocPath), "toc requires a chapter-selector")
+			}
+			v.checkSelector(tocPath+".chapter-selector", source.Toc.ChapterSelector)
+			v.validatePagination(tocPath+".pagination", &source.Toc.Pagination)
+			v.validateSourceContent(tocPath, &source.Toc.SourceContent)
+		}
+		if source.Chapter != nil {
+			chapterPath := path + ".chapter"
+			if source.Chapter.URL == "" {
+				v.addIssue(v.lineOfPath(chapterPath), "chapter requires an url")
+			}
+			v.checkURL(chapterPath+".url", source.Chapter.URL)
+			v.validateSourceContent(chapterPath, &source.Chapter.SourceContent)
+		}
+	}
+}
+
+// validateSourceContent validates the title and chapter content of a source
+func (v *validator) validateSourceContent(path string, content *SourceContent) {
+	v.validateTitleContent(path+".title-content", &content.TitleContent)
+	v.validateChapterContent(path+".chapter-content", &content.ChapterContent)
+}
+
+// validatePagination validates the next page selector of the pagination
+func (v *validator) validatePagination(path string, pagination *Pagination) {
+	if pagination.NextPageSelector != nil {
+		v.checkSelector(path+".next-page-selector", *pagination.NextPageSelector)
+	}
+}
+
+// validateTitleContent validates the title selector and the title cleanup options
+func (v *validator) validateTitleContent(path string, content *TitleContent) {
+	if content.TitleSelector != nil {
+		v.checkSelector(path+".title-selector", *content.TitleSelector)
+	}
+	v.validateCleanupOptions(path, &content.CleanupOptions, "Title")
+}
+
+// validateChapterContent validates the content selector and the content cleanup options
+func (v *validator) validateChapterContent(path string, content *ChapterContent) {
+	if content.ContentSelector != nil {
+		v.checkSelector(path+".content-selector", *content.ContentSelector)
+	}
+	v.validateCleanupOptions(path, &content.CleanupOptions, "Content")
+}
+
+// validateCleanupOptions validates the prefix/suffix selectors and the regular expressions
+// the strip regex requires the passed capture group to be defined
+func (v *validator) validateCleanupOptions(path string, options *CleanupOptions, captureGroup string) {
+	if options.PrefixSelectors != nil {
+		for i, selector := range *options.PrefixSelectors {
+			v.checkSelector(fmt.Sprintf("%s.prefix-selectors.%d", path, i), selector)
+		}
+	}
+	if options.SuffixSelectors != nil {
+		for i, selector := range *options.SuffixSelectors {
+			v.checkSelector(fmt.Sprintf("%s.suffix-selectors.%d", path, i), selector)
+		}
+	}
+	if re := v.checkRegex(path+".strip-regex", options.StripRegex); re != nil {
+		if re.SubexpIndex(captureGroup) < 0 {
+			v.addIssue(
+				v.lineOfPath(path+".strip-regex"),
+				"strip-regex requires the named capture group (?P<%s>...)", captureGroup,
+			)
+		}
+	}
+	v.checkRegex(path+".cleanup-regex", options.CleanupRegex)
+	if options.Transforms != nil {
+		for i, transform := range *options.Transforms {
+			v.validateTransform(fmt.Sprintf("%s.transforms.%d", path, i), &transform)
+		}
+	}
+}
+
+// validateTransform validates the selectors of a single transformation step
+func (v *validator) validateTransform(path string, transform *Transform) {
+	switch {
+	case transform.Remove != "":
+		v.checkSelector(path+".remove", transform.Remove)
+	case transform.Unwrap != "":
+		v.checkSelector(path+".unwrap", transform.Unwrap)
+	case transform.Keep != "":
+		v.checkSelector(path+".keep", transform.Keep)
+	case transform.Rename != nil:
+		v.checkSelector(path+".rename.selector", transform.Rename.Selector)
+	case transform.SetAttribute != nil:
+		v.checkSelector(path+".set-attribute.selector", transform.SetAttribute.Selector)
+	case transform.RemoveAttribute != nil:
+		v.checkSelector(path+".remove-attribute.selector", transform.RemoveAttribute.Selector)
+	case transform.Replace != nil && transform.Replace.pattern != nil:
+		v.checkReplacementGroups(path+".replace.with", transform.Replace.pattern, transform.Replace.With)
+	}
+}
+
+// checkSelector checks if the passed CSS selector can be compiled
+func (v *validator) checkSelector(path string, selector string) {
+	if selector == "" || !v.isDefined(path) {
+		return
+	}
+	if _, err := cascadia.Compile(selector); err != nil {
+		v.addIssue(v.lineOfPath(path), "invalid CSS selector %q: %s", selector, err)
+	}
+}
+
+// checkRegex checks if the passed regular expression can be compiled and returns the compiled expression
+// returns nil if the expression is not set, not defined at this path or invalid
+func (v *validator) checkRegex(path string, expression string) *regexp.Regexp {
+	if expression == "" || !v.isDefined(path) {
+		return nil
+	}
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		v.addIssue(v.lineOfPath(path), "invalid regular expression: %s", err)
+		return nil
+	}
+	return re
+}
+
+// checkReplacementGroups checks if all capture groups referenced in the replacement exist in the pattern
+func (v *validator) checkReplacementGroups(path string, pattern *regexp.Regexp, replacement string) {
+	for _, match := range replacementGroupPattern.FindAllStringSubmatch(replacement, -1) {
+		group := strings.Trim(match[1], "{}")
+		if index, err := strconv.Atoi(group); err == nil {
+			if index > pattern.NumSubexp() {
+				v.addIssue(v.lineOfPath(path), "replacement references undefined capture group $%s", group)
+			}
+		} else if pattern.SubexpIndex(group) < 0 {
+			v.addIssue(v.lineOfPath(path), "replacement references undefined capture group $%s", group)
+		}
+	}
+}
+
+// checkURL checks if the passed URL is an absolute HTTP(S) URL
+func (v *validator) checkURL(path string, rawURL string) {
+	if !v.isDefined(path) {
+		return
+	}
+	parsedURL, err := url.Parse(rawURL)
+	switch {
+	case err != nil:
+		v.addIssue(v.lineOfPath(path), "invalid URL: %s", err)
+	case parsedURL.Scheme != "http" && parsedURL.Scheme != "https":
+		v.addIssue(v.lineOfPath(path), "URL %q requires the scheme http or https", rawURL)
+	case parsedURL.Host == "":
+		v.addIssue(v.lineOfPath(path), "URL %q has no host", rawURL)
+	}
+}
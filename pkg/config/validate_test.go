@@ -0,0 +1,110 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigurationFile(t *testing.T) {
+	fileName := writeTestConfiguration(t, `general:
+  title: Novel
+sites:
+  - host: www.example.com
+    chapter-content:
+      content-selector: "div[["
+chapters:
+  - toc:
+      url: www.example.com/toc
+      chapter-selector: a.chapter
+      title-content:
+        strip-regex: "(?P<Chapter>.+)"
+  - chapter:
+      url: https://www.example.com/chapter-1
+blacklist:
+  - ftp://www.example.com/chapter-2
+`)
+
+	issues, err := NewParser().ValidateConfigurationFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		line    int
+		message string
+	}{
+		{6, "invalid CSS selector"},
+		{9, "requires the scheme http or https"},
+		{12, "(?P<Title>...)"},
+		{16, "requires the scheme http or https"},
+	}
+	if len(issues) != len(expected) {
+		for _, issue := range issues {
+			t.Log(issue.String())
+		}
+		t.Fatalf("expected %d issues, got %d", len(expected), len(issues))
+	}
+	for i, issue := range issues {
+		if issue.File != fileName || issue.Line != expected[i].line || !strings.Contains(issue.Message, expected[i].message) {
+			t.Errorf(
+				"unexpected issue %q, expected line %d containing %q", issue.String(), expected[i].line, expected[i].message,
+			)
+		}
+	}
+
+	// invalid entries are all reported with the line of the invalid value
+	issues, err = NewParser().ValidateConfigurationFile(writeTestConfiguration(t, `sites:
+  - host: www.example.com
+    host-regex: example
+  - host-regex: "([a-"
+  - host: www.example.org
+    rate-limit: -1s
+  - host: www.example.net
+    backoff:
+      strategy: fibonacci
+blacklist:
+  - regex: "([a-"
+  - url: https://www.example.com/teaser-*
+    title: "*Teaser*"
+replacements:
+  - from-regex: ^https://old\.example\.com/(.+)$
+    to: https://www.example.com/$2
+  - url: https://www.example.com/chapter-1
+    from-regex: chapter-1
+chapters:
+  - chapter:
+      url: https://www.example.com/chapter-1
+      chapter-content:
+        transforms:
+          - remove: .ad
+            unwrap: span
+          - replace:
+              regex: "([a-"
+volumes:
+  title-regex: "([a-"
+emojis:
+  mode: unicode
+proxy: ftp://proxy.example.com
+concurrency: -1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLines := []int{2, 4, 5, 8, 11, 12, 16, 17, 24, 27, 29, 30, 32, 33}
+	if len(issues) != len(expectedLines) {
+		t.Fatalf("expected %d issues for the invalid entries, got %v", len(expectedLines), issues)
+	}
+	for i, issue := range issues {
+		if issue.Line != expectedLines[i] {
+			t.Errorf("unexpected issue %q, expected line %d", issue.String(), expectedLines[i])
+		}
+	}
+
+	issues, err = NewParser().ValidateConfigurationFile(writeTestConfiguration(t, `
+chapters:
+  - chapter:
+      url: https://www.example.com/chapter-1
+`))
+	if err != nil || len(issues) != 0 {
+		t.Errorf("expected no issues for a valid configuration, got %v and error %v", issues, err)
+	}
+}
@@ -0,0 +1,91 @@
+package epub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// importImage adds the passed image source to the epub and returns the internal path
+// remote images are downloaded through the session to use the response cache, rate limits and site options
+// images used multiple times, f.e. emoji images, are only imported once
+func (w *Writer) importImage(ctx context.Context, source string, fileName string) (internalPath string, err error) {
+	if internalPath, imported := w.importedImages[source]; imported {
+		return internalPath, nil
+	}
+	if w.session == nil || !w.isRemoteSource(source) {
+		internalPath, err = w.Epub.AddImage(source, fileName)
+	} else {
+		var localPath string
+		if localPath, err = w.downloadImage(ctx, source, fileName); err != nil {
+			return "", err
+		}
+		internalPath, err = w.Epub.AddImage(localPath, fileName)
+	}
+	if err != nil {
+		return "", err
+	}
+	w.importedImages[source] = internalPath
+	if w.imageObserver != nil {
+		w.imageObserver.ImageImported(source, internalPath)
+	}
+	return internalPath, nil
+}
+
+// isRemoteSource checks if the passed source is a HTTP or HTTPS URL
+func (w *Writer) isRemoteSource(source string) bool {
+	lowerSource := strings.ToLower(source)
+	return strings.HasPrefix(lowerSource, "http://") || strings.HasPrefix(lowerSource, "https://")
+}
+
+// downloadImage downloads the passed image into the temporary image directory and returns the local path
+// the library copies the images on writing the epub, so the files have to exist until then
+func (w *Writer) downloadImage(ctx context.Context, source string, fileName string) (string, error) {
+	if w.imageDirectory == "" {
+		directory, err := ioutil.TempDir("", "epub-scraper-images")
+		if err != nil {
+			return "", err
+		}
+		w.imageDirectory = directory
+	}
+
+	response, err := w.session.Get(ctx, source)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to download image %s, status code: %d", source, response.StatusCode)
+	}
+
+	localPath := filepath.Join(w.imageDirectory, fileName)
+	file, err := os.Create(filepath.Clean(localPath))
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(file, response.Body); err != nil {
+		_ = file.Close()
+		return "", err
+	}
+	return localPath, file.Close()
+}
+
+// removeImageDirectory removes the temporary image directory after the images got copied into the epub
+func (w *Writer) removeImageDirectory() {
+	if w.imageDirectory == "" {
+		return
+	}
+	if err := os.RemoveAll(w.imageDirectory); err != nil {
+		log.Warningf("unable to remove temporary image directory %s: %s", w.imageDirectory, err.Error())
+	}
+	w.imageDirectory = ""
+}
@@ -0,0 +1,148 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// internal paths of the navigation documents generated by the bmaupin/go-epub library
+const (
+	navigationDocumentPath = "EPUB/nav.xhtml"
+	ncxDocumentPath        = "EPUB/toc.ncx"
+	sectionFolder          = "xhtml/"
+)
+
+var (
+	navigationListPattern = regexp.MustCompile(`(?s)<ol>.*</ol>`)
+	ncxNavMapPattern      = regexp.MustCompile(`(?s)<navMap>.*</navMap>`)
+)
+
+// navigationPoint is an entry of the navigation of the epub, volumes contain their chapters as children
+type navigationPoint struct {
+	title    string
+	fileName string
+	children []*navigationPoint
+}
+
+// hasVolumes checks if any of the added chapters is grouped into a volume
+func (w *Writer) hasVolumes() bool {
+	for _, savedChapter := range w.chapters {
+		if savedChapter.volume != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// getVolumeFileName returns the file name of the title page of the next written volume
+// volumes are numbered in the order of their first appearance
+func (w *Writer) getVolumeFileName() string {
+	volumeIndex := 0
+	for _, point := range w.navigation {
+		if strings.HasPrefix(point.fileName, "volume") {
+			volumeIndex++
+		}
+	}
+	return fmt.Sprintf("volume%04d.xhtml", volumeIndex+1)
+}
+
+// writeNestedNavigation replaces the flat navigation generated by the bmaupin/go-epub library
+// with the nested navigation of the volumes in the passed epub file
+func (w *Writer) writeNestedNavigation(path string) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+
+	buffer := new(bytes.Buffer)
+	archive := zip.NewWriter(buffer)
+	for _, file := range reader.File {
+		content, err := w.readZipFile(file)
+		if err != nil {
+			_ = reader.Close()
+			return err
+		}
+
+		switch file.Name {
+		case navigationDocumentPath:
+			content = navigationListPattern.ReplaceAllLiteral(content, []byte(w.getNavigationList(w.navigation)))
+		case ncxDocumentPath:
+			navPointIndex := 0
+			content = ncxNavMapPattern.ReplaceAllLiteral(
+				content, []byte("<navMap>"+w.getNcxNavPoints(w.navigation, &navPointIndex)+"</navMap>"),
+			)
+		}
+
+		// copy the header to keep the compression method, the mimetype file has to stay uncompressed
+		header := file.FileHeader
+		fileWriter, err := archive.CreateHeader(&header)
+		if err != nil {
+			_ = reader.Close()
+			return err
+		}
+		if _, err = fileWriter.Write(content); err != nil {
+			_ = reader.Close()
+			return err
+		}
+	}
+
+	if err = reader.Close(); err != nil {
+		return err
+	}
+	if err = archive.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buffer.Bytes(), 0644)
+}
+
+// readZipFile returns the uncompressed content of the passed zip file entry
+func (w *Writer) readZipFile(file *zip.File) ([]byte, error) {
+	fileReader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(fileReader)
+	if closeErr := fileReader.Close(); err == nil {
+		err = closeErr
+	}
+	return content, err
+}
+
+// getNavigationList returns the nested ordered list of the passed navigation points for the EPUB v3 navigation
+func (w *Writer) getNavigationList(points []*navigationPoint) string {
+	list := "<ol>"
+	for _, point := range points {
+		list += fmt.Sprintf(
+			`<li><a href="%s">%s</a>`,
+			filepath.ToSlash(sectionFolder+point.fileName),
+			html.EscapeString(point.title),
+		)
+		if len(point.children) > 0 {
+			list += w.getNavigationList(point.children)
+		}
+		list += "</li>"
+	}
+	return list + "</ol>"
+}
+
+// getNcxNavPoints returns the nested navigation points of the passed navigation points for the EPUB v2 navigation
+func (w *Writer) getNcxNavPoints(points []*navigationPoint, navPointIndex *int) string {
+	navPoints := ""
+	for _, point := range points {
+		*navPointIndex++
+		navPoints += fmt.Sprintf(
+			`<navPoint id="navPoint-%d"><navLabel><text>%s</text></navLabel><content src="%s"/>`,
+			*navPointIndex,
+			html.EscapeString(point.title),
+			filepath.ToSlash(sectionFolder+point.fileName),
+		)
+		navPoints += w.getNcxNavPoints(point.children, navPointIndex) + "</navPoint>"
+	}
+	return navPoints
+}
@@ -0,0 +1,90 @@
+package epub
+
+import (
+	"archive/zip"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+func TestNestedNavigation(t *testing.T) {
+	ctx := context.Background()
+	writer, err := NewWriter(ctx, &config.NovelConfig{General: config.General{Title: "Novel"}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addedChapter := range []struct {
+		title  string
+		volume string
+	}{
+		{"Prologue", ""},
+		{"Chapter 1", "Volume 1"},
+		{"Chapter 2", "Volume 1"},
+		{"Chapter 3", "Volume 2"},
+		{"Epilogue", ""},
+	} {
+		if err = writer.AddChapter(ctx, addedChapter.title, "<p>content</p>", false, addedChapter.volume); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.createToC(); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.writeChapters(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<ol>` +
+		`<li><a href="xhtml/content.xhtml">Table of Contents</a></li>` +
+		`<li><a href="xhtml/chapter0001.xhtml">Prologue</a></li>` +
+		`<li><a href="xhtml/volume0001.xhtml">Volume 1</a><ol>` +
+		`<li><a href="xhtml/chapter0002.xhtml">Chapter 1</a></li>` +
+		`<li><a href="xhtml/chapter0003.xhtml">Chapter 2</a></li>` +
+		`</ol></li>` +
+		`<li><a href="xhtml/volume0002.xhtml">Volume 2</a><ol>` +
+		`<li><a href="xhtml/chapter0004.xhtml">Chapter 3</a></li>` +
+		`</ol></li>` +
+		`<li><a href="xhtml/chapter0005.xhtml">Epilogue</a></li>` +
+		`</ol>`
+	if actual := writer.getNavigationList(writer.navigation); actual != expected {
+		t.Fatalf("unexpected navigation list\nexpected: %s\nactual:   %s", expected, actual)
+	}
+
+	// the flat navigation of the written epub gets replaced with the nested navigation
+	path := filepath.Join(t.TempDir(), "novel.epub")
+	if err = writer.Epub.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.writeNestedNavigation(path); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	documents := map[string]string{
+		navigationDocumentPath: expected,
+		ncxDocumentPath:        `<content src="xhtml/volume0001.xhtml"/><navPoint id="navPoint-4">`,
+	}
+	for _, file := range reader.File {
+		if expectedContent, ok := documents[file.Name]; ok {
+			content, err := writer.readZipFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(content), expectedContent) {
+				t.Errorf("%s doesn't contain the nested navigation %s", file.Name, expectedContent)
+			}
+			delete(documents, file.Name)
+		}
+	}
+	if len(documents) > 0 {
+		t.Errorf("missing navigation documents %v", documents)
+	}
+}
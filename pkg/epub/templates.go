@@ -6,78 +6,100 @@ import (
 	"html"
 	"html/template"
 
-	"github.com/DaRealFreak/epub-scraper/pkg/raven"
 	"github.com/DaRealFreak/epub-scraper/pkg/version"
 )
 
+// executeTemplate parses the passed template and returns the template executed with the passed data
+func (w *Writer) executeTemplate(content string, data map[string]interface{}) (string, error) {
+	t, err := template.New("").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	buffer := new(bytes.Buffer)
+	if err = t.Execute(buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
 // getChapterTitle returns the chapter title parsed with the configured template
-func (w *Writer) getChapterTitle(savedChapter chapter, chapterIndex int) string {
+func (w *Writer) getChapterTitle(savedChapter chapter, chapterIndex int) (string, error) {
 	chapterTitle := savedChapter.title
 	// add prefix if requested (optional since many add it already in the ToC)
 	if savedChapter.addPrefix {
 		if w.cfg.Templates.Chapter.Title == "" {
 			w.cfg.Templates.Chapter.Title = `Chapter {{.chapterIndex}} - {{.chapterTitle}}`
 		}
-		chapterTemplate := template.Must(template.New("").Parse(w.cfg.Templates.Chapter.Title))
-		buffer := new(bytes.Buffer)
-		raven.CheckError(chapterTemplate.Execute(buffer, map[string]interface{}{
+		return w.executeTemplate(w.cfg.Templates.Chapter.Title, map[string]interface{}{
 			"chapterIndex": chapterIndex + 1,
 			"chapterTitle": chapterTitle,
-		}))
-		chapterTitle = buffer.String()
+		})
 	}
-	return chapterTitle
+	return chapterTitle, nil
 }
 
 // getTranslators returns the translator list parsed with the configured template
-func (w *Writer) getTranslators() string {
+func (w *Writer) getTranslators() (string, error) {
 	if w.cfg.Templates.ToC.Translator == "" {
 		w.cfg.Templates.ToC.Translator = `<a href="{{.translatorURL}}">{{.translatorName}}</a><br/>`
 	}
-	translatorTemplate := template.Must(template.New("").Parse(w.cfg.Templates.ToC.Translator))
 	translators := ""
 	for _, translator := range w.cfg.General.Translators {
-		buffer := new(bytes.Buffer)
-		raven.CheckError(translatorTemplate.Execute(buffer, map[string]interface{}{
+		translatorLink, err := w.executeTemplate(w.cfg.Templates.ToC.Translator, map[string]interface{}{
 			"translatorURL":  translator.URL,
 			"translatorName": translator.Name,
-		}))
-		translators += buffer.String()
+		})
+		if err != nil {
+			return "", err
+		}
+		translators += translatorLink
 	}
-	return translators
+	return translators, nil
 }
 
 // getAltTitle returns the parsed template of the optional alt title
 // if no alt title is defined it'll return an empty string
-func (w *Writer) getAltTitle() string {
+func (w *Writer) getAltTitle() (string, error) {
 	// since alt title is optional we set it only if not empty
-	altTitle := ""
-	if w.cfg.General.AltTitle != "" {
-		if w.cfg.Templates.ToC.AltTitle == "" {
-			w.cfg.Templates.ToC.AltTitle = `<h4><i>- {{.altTitle}} -</i></h4>`
-		}
-		altTitleTemplate := template.Must(template.New("").Parse(w.cfg.Templates.ToC.AltTitle))
-		buffer := new(bytes.Buffer)
-		raven.CheckError(altTitleTemplate.Execute(buffer, map[string]interface{}{
-			"altTitle": html.EscapeString(w.cfg.General.AltTitle),
-		}))
-		altTitle = buffer.String()
+	if w.cfg.General.AltTitle == "" {
+		return "", nil
+	}
+	if w.cfg.Templates.ToC.AltTitle == "" {
+		w.cfg.Templates.ToC.AltTitle = `<h4><i>- {{.altTitle}} -</i></h4>`
 	}
-	return altTitle
+	return w.executeTemplate(w.cfg.Templates.ToC.AltTitle, map[string]interface{}{
+		"altTitle": html.EscapeString(w.cfg.General.AltTitle),
+	})
 }
 
 // getToC returns a table of contents consisting of a simple list of links to the chapter with the chapter title as name
-func (w *Writer) getToC() string {
+// chapters grouped into volumes are listed below a link to the title page of their volume
+func (w *Writer) getToC() (string, error) {
 	toc := ""
+	currentVolume := ""
+	volumeIndex := 0
 	for index, savedChapter := range w.chapters {
-		chapterTitle := w.getChapterTitle(savedChapter, index)
+		if savedChapter.volume != "" && savedChapter.volume != currentVolume {
+			volumeIndex++
+			toc += fmt.Sprintf(
+				`<h4><a href="volume%04d.xhtml">%s</a></h4>`,
+				volumeIndex,
+				html.EscapeString(savedChapter.volume),
+			)
+		}
+		currentVolume = savedChapter.volume
+
+		chapterTitle, err := w.getChapterTitle(savedChapter, index)
+		if err != nil {
+			return "", err
+		}
 		toc += fmt.Sprintf(
 			`<p><a href="chapter%04d.xhtml">%s</a></p>`,
 			index+1,
 			chapterTitle,
 		)
 	}
-	return toc
+	return toc, nil
 }
 
 // getEpubScraperCredits returns the epub scraper credits including a link to the repository
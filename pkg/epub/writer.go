@@ -1,19 +1,21 @@
 package epub
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"html/template"
+	"io"
+	"io/ioutil"
 	"math/rand"
 	"mime"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/DaRealFreak/epub-scraper/pkg/config"
-	"github.com/DaRealFreak/epub-scraper/pkg/raven"
+	"github.com/DaRealFreak/epub-scraper/pkg/session"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/bmaupin/go-epub"
 	"github.com/microcosm-cc/bluemonday"
@@ -26,6 +28,7 @@ type chapter struct {
 	title     string
 	content   string
 	addPrefix bool
+	volume    string
 }
 
 // Writer contains all information and functions to create the final .epub file
@@ -33,24 +36,51 @@ type Writer struct {
 	Epub     *epub.Epub
 	chapters []chapter
 	cfg      *config.NovelConfig
+	// navigation points of the written sections used for the nested navigation of volumes
+	navigation []*navigationPoint
 	// rate limiter for importing assets
 	RateLimiter *rate.Limiter
-	ctx         context.Context
 	sanitizer   *bluemonday.Policy
+	// session used for downloading remote images, the images are imported directly if no session is set
+	session session.Session
+	// temporary directory containing the downloaded images until the epub is written
+	imageDirectory string
+	// internal paths of the already imported images by their source
+	importedImages map[string]string
+	// incomplete epubs of interrupted runs are marked in the title and the file name
+	incomplete bool
+	// observer notified about every imported image, nil if no observer is set
+	imageObserver ImageObserver
 }
 
-// NewWriter returns a Writer struct
-func NewWriter(cfg *config.NovelConfig) *Writer {
+// ImageObserver is notified about every image imported into the epub
+type ImageObserver interface {
+	ImageImported(source string, internalPath string)
+}
+
+// NewWriter returns a Writer struct, remote images are downloaded through the passed session if set
+// and the passed image observer is notified about every imported image if set
+// the cover is no longer imported after the passed context got canceled
+// returns an error if the configured assets or the cover can't be imported
+func NewWriter(
+	ctx context.Context, cfg *config.NovelConfig, imageSession session.Session, imageObserver ImageObserver,
+) (*Writer, error) {
 	writer := &Writer{
-		cfg:         cfg,
-		RateLimiter: rate.NewLimiter(rate.Every(1500*time.Millisecond), 1),
-		ctx:         context.Background(),
-		sanitizer:   bluemonday.UGCPolicy(),
+		cfg:            cfg,
+		RateLimiter:    rate.NewLimiter(rate.Every(1500*time.Millisecond), 1),
+		sanitizer:      bluemonday.UGCPolicy(),
+		session:        imageSession,
+		imageObserver:  imageObserver,
+		importedImages: make(map[string]string),
 	}
 	writer.createEpub()
-	writer.importAssets()
-	writer.importAndAddCover()
-	return writer
+	if err := writer.importAssets(); err != nil {
+		return nil, err
+	}
+	if err := writer.importAndAddCover(ctx); err != nil {
+		return nil, err
+	}
+	return writer, nil
 }
 
 // createEpub creates epub writer and sets the available metadata taken from the configuration
@@ -66,36 +96,105 @@ func (w *Writer) createEpub() {
 	log.Infof("set language to: %s", w.cfg.General.Language)
 }
 
-// WriteEpub writes the generated epub to the file system
-func (w *Writer) WriteEpub() {
-	w.createToC()
-	w.writeChapters()
-	// save the .epub file to the drive
-	path, err := filepath.Abs(filepath.Clean(w.cfg.General.Title + ".epub"))
-	raven.CheckError(err)
-	raven.CheckError(w.Epub.Write(path))
-	log.Infof("epub saved to %s", path)
+// MarkIncomplete marks the epub as incomplete for writing the already added chapters of interrupted runs
+func (w *Writer) MarkIncomplete() {
+	w.incomplete = true
+	w.Epub.SetTitle(w.getTitle())
+}
+
+// getTitle returns the title of the epub, which also is used as file name
+func (w *Writer) getTitle() string {
+	if w.incomplete {
+		return w.cfg.General.Title + " (incomplete)"
+	}
+	return w.cfg.General.Title
+}
+
+// GetFileName returns the file name of the epub based on the title
+func (w *Writer) GetFileName() string {
+	return w.getTitle() + ".epub"
+}
+
+// WriteEpubTo writes the generated epub into the passed output and polishes it before if polish is set
+// the bmaupin/go-epub library and calibre only work on files, so the epub is written into a temporary file first
+func (w *Writer) WriteEpubTo(output io.Writer, polish bool) error {
+	directory, err := ioutil.TempDir("", "epub-scraper-output")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(directory); err != nil {
+			log.Warningf("unable to remove temporary output directory %s: %s", directory, err.Error())
+		}
+	}()
+
+	path := filepath.Join(directory, "output.epub")
+	if err = w.writeEpubFile(path); err != nil {
+		return err
+	}
+	if polish {
+		if err = w.polishEpubFile(path); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	_, err = io.Copy(output, file)
+	return err
+}
+
+// writeEpubFile writes the generated epub to the passed path
+func (w *Writer) writeEpubFile(path string) error {
+	if err := w.createToC(); err != nil {
+		return err
+	}
+	if err := w.writeChapters(); err != nil {
+		return err
+	}
+	err := w.Epub.Write(path)
+	w.removeImageDirectory()
+	if err != nil {
+		return fmt.Errorf("unable to write epub to %s: %w", path, err)
+	}
+	if w.hasVolumes() {
+		if err = w.writeNestedNavigation(path); err != nil {
+			return fmt.Errorf("unable to write nested navigation to %s: %w", path, err)
+		}
+	}
+	return nil
 }
 
-// PolishEpub uses calibres ebook-polish command to compress images and fix possible errors
-// which occurred to me multiple times using the bmaupin/go-epub library
-func (w *Writer) PolishEpub() {
-	path, err := filepath.Abs(filepath.Clean(w.cfg.General.Title + ".epub"))
-	raven.CheckError(err)
+// polishEpubFile polishes the epub at the passed path in place with calibres ebook-polish command
+// to compress images and fix possible errors which occurred to me multiple times using the bmaupin/go-epub library
+func (w *Writer) polishEpubFile(path string) error {
 	// #nosec
-	raven.CheckError(exec.Command("ebook-polish", "-U", "-i", path, path).Run())
+	if err := exec.Command("ebook-polish", "-U", "-i", path, path).Run(); err != nil {
+		return fmt.Errorf("unable to polish epub %s: %w", path, err)
+	}
 	log.Infof("generated epub got successfully polished")
+	return nil
 }
 
 // AddChapter adds a chapter to the to our current chapter list
-func (w *Writer) AddChapter(title string, content string, addPrefix bool) {
-	w.extractAndImportImages(&content, len(w.chapters)+1)
-	w.chapters = append(w.chapters, chapter{title: title, content: content, addPrefix: addPrefix})
+// chapters with an empty volume are not grouped into any volume
+// images of the chapter are no longer imported after the passed context got canceled
+func (w *Writer) AddChapter(ctx context.Context, title string, content string, addPrefix bool, volume string) error {
+	if err := w.extractAndImportImages(ctx, &content, len(w.chapters)+1); err != nil {
+		return err
+	}
+	w.chapters = append(w.chapters, chapter{title: title, content: content, addPrefix: addPrefix, volume: volume})
+	return nil
 }
 
 // createToC creates a table of contents page to jump directly to chapters
 // uses the previously appended chapters to link them
-func (w *Writer) createToC() {
+func (w *Writer) createToC() error {
 	if w.cfg.Templates.ToC.Content == "" {
 		w.cfg.Templates.ToC.Content = `
 		<div>
@@ -114,34 +213,61 @@ func (w *Writer) createToC() {
 			</div>
         </div>`
 	}
-	t := template.Must(template.New("").Parse(w.cfg.Templates.ToC.Content))
-
-	toc := w.getToC()
+	toc, err := w.getToC()
+	if err != nil {
+		return err
+	}
+	altTitle, err := w.getAltTitle()
+	if err != nil {
+		return err
+	}
+	translators, err := w.getTranslators()
+	if err != nil {
+		return err
+	}
 
-	contentBuffer := new(bytes.Buffer)
 	// #nosec
-	raven.CheckError(t.Execute(contentBuffer, map[string]interface{}{
+	content, err := w.executeTemplate(w.cfg.Templates.ToC.Content, map[string]interface{}{
 		"title":              w.cfg.General.Title,
-		"altTitle":           template.HTML(w.sanitizer.Sanitize(w.getAltTitle())),
+		"altTitle":           template.HTML(w.sanitizer.Sanitize(altTitle)),
 		"rawUrl":             w.cfg.General.Raw,
 		"author":             w.cfg.General.Author,
 		"toc":                template.HTML(w.sanitizer.Sanitize(toc)),
-		"translators":        template.HTML(w.sanitizer.Sanitize(w.getTranslators())),
+		"translators":        template.HTML(w.sanitizer.Sanitize(translators)),
 		"epubScraperCredits": template.HTML(w.sanitizer.Sanitize(w.getEpubScraperCredits())),
-	}))
-	_, err := w.Epub.AddSection(
-		contentBuffer.String(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = w.Epub.AddSection(
+		content,
 		"Table of Contents",
 		"content.xhtml",
 		w.cfg.Assets.CSS.InternalPath,
-	)
-	raven.CheckError(err)
+	); err != nil {
+		return err
+	}
+	w.navigation = append(w.navigation, &navigationPoint{title: "Table of Contents", fileName: "content.xhtml"})
+	return nil
 }
 
 // writeChapters writes all appended chapters to the epub file
-func (w *Writer) writeChapters() {
+// a volume title page is added before the first chapter of each volume
+func (w *Writer) writeChapters() (err error) {
+	var currentVolume *navigationPoint
 	for index, savedChapter := range w.chapters {
-		chapterTitle := w.getChapterTitle(savedChapter, index)
+		if savedChapter.volume == "" {
+			currentVolume = nil
+		} else if currentVolume == nil || currentVolume.title != savedChapter.volume {
+			if currentVolume, err = w.writeVolume(savedChapter.volume); err != nil {
+				return err
+			}
+		}
+
+		chapterTitle, err := w.getChapterTitle(savedChapter, index)
+		if err != nil {
+			return err
+		}
 		if w.cfg.Templates.Chapter.Content == "" {
 			w.cfg.Templates.Chapter.Content = `
 				<div class="left" style="text-align:left;text-indent:0;">
@@ -150,35 +276,78 @@ func (w *Writer) writeChapters() {
 					{{.content}}
 				</div>`
 		}
-		t := template.Must(template.New("").Parse(w.cfg.Templates.Chapter.Content))
 
-		contentBuffer := new(bytes.Buffer)
 		// #nosec
-		raven.CheckError(t.Execute(contentBuffer, map[string]interface{}{
+		content, err := w.executeTemplate(w.cfg.Templates.Chapter.Content, map[string]interface{}{
 			"chapterTitle": template.HTML(w.sanitizer.Sanitize(chapterTitle)),
 			// #nosec
 			"content": template.HTML(w.sanitizer.Sanitize(savedChapter.content)),
-		}))
+		})
+		if err != nil {
+			return err
+		}
 
-		_, err := w.Epub.AddSection(
-			contentBuffer.String(),
+		fileName := fmt.Sprintf("chapter%04d.xhtml", index+1)
+		if _, err = w.Epub.AddSection(
+			content,
 			chapterTitle,
-			fmt.Sprintf("chapter%04d.xhtml", index+1),
+			fileName,
 			w.cfg.Assets.CSS.InternalPath,
-		)
-		raven.CheckError(err)
+		); err != nil {
+			return err
+		}
+
+		chapterPoint := &navigationPoint{title: chapterTitle, fileName: fileName}
+		if currentVolume != nil {
+			currentVolume.children = append(currentVolume.children, chapterPoint)
+		} else {
+			w.navigation = append(w.navigation, chapterPoint)
+		}
 	}
+	return nil
+}
+
+// writeVolume writes the title page of the passed volume to the epub file and returns the navigation point
+func (w *Writer) writeVolume(volumeTitle string) (*navigationPoint, error) {
+	if w.cfg.Templates.Volume.Content == "" {
+		w.cfg.Templates.Volume.Content = `
+			<div class="center">
+				<h2>{{.volumeTitle}}</h2>
+			</div>`
+	}
+	content, err := w.executeTemplate(w.cfg.Templates.Volume.Content, map[string]interface{}{
+		"volumeTitle": volumeTitle,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := w.getVolumeFileName()
+	if _, err = w.Epub.AddSection(
+		content,
+		volumeTitle,
+		fileName,
+		w.cfg.Assets.CSS.InternalPath,
+	); err != nil {
+		return nil, err
+	}
+
+	volumePoint := &navigationPoint{title: volumeTitle, fileName: fileName}
+	w.navigation = append(w.navigation, volumePoint)
+	return volumePoint, nil
 }
 
 // importAssets adds the specified assets to the epub
-func (w *Writer) importAssets() {
+func (w *Writer) importAssets() error {
 	if w.cfg.Assets.CSS.HostPath != "" {
 		if !filepath.IsAbs(w.cfg.Assets.CSS.HostPath) {
 			// if not an absolute path we combine it with our configuration file bath
 			w.cfg.Assets.CSS.HostPath = filepath.Join(w.cfg.BaseDirectory, w.cfg.Assets.CSS.HostPath)
 		}
 		internalPath, err := w.Epub.AddCSS(w.cfg.Assets.CSS.HostPath, filepath.Base(w.cfg.Assets.CSS.HostPath))
-		raven.CheckError(err)
+		if err != nil {
+			return fmt.Errorf("unable to import CSS file %s: %w", w.cfg.Assets.CSS.HostPath, err)
+		}
 		w.cfg.Assets.CSS.InternalPath = internalPath
 		log.Infof("imported CSS file: %s", w.cfg.Assets.CSS.HostPath)
 	}
@@ -188,53 +357,74 @@ func (w *Writer) importAssets() {
 			w.cfg.Assets.Font.HostPath = filepath.Join(w.cfg.BaseDirectory, w.cfg.Assets.Font.HostPath)
 		}
 		internalPath, err := w.Epub.AddFont(w.cfg.Assets.Font.HostPath, filepath.Base(w.cfg.Assets.Font.HostPath))
-		raven.CheckError(err)
+		if err != nil {
+			return fmt.Errorf("unable to import font file %s: %w", w.cfg.Assets.Font.HostPath, err)
+		}
 		w.cfg.Assets.Font.InternalPath = internalPath
 		log.Infof("imported font file: %s", w.cfg.Assets.Font.HostPath)
 	}
+	return nil
 }
 
 // importAndAddCover adds the specified cover to the epub
-func (w *Writer) importAndAddCover() {
+func (w *Writer) importAndAddCover(ctx context.Context) error {
 	// no need to add cover if no cover is set
 	if w.cfg.General.Cover == "" {
-		return
+		return nil
 	}
 
-	internalFilePath, err := w.Epub.AddImage(w.cfg.General.Cover, "cover"+filepath.Ext(w.cfg.General.Cover))
-	raven.CheckError(err)
+	internalFilePath, err := w.importImage(ctx, w.cfg.General.Cover, "cover"+filepath.Ext(w.cfg.General.Cover))
+	if err != nil {
+		return fmt.Errorf("unable to import cover %s: %w", w.cfg.General.Cover, err)
+	}
 
 	w.Epub.SetCover(internalFilePath, w.cfg.Assets.CSS.InternalPath)
 	log.Infof("set cover to: %s", w.cfg.General.Cover)
+	return nil
 }
 
 // extractAndImportImages extracts all external images, imports them into the epub and updates the display links
-func (w *Writer) extractAndImportImages(content *string, chapterIndex int) {
+func (w *Writer) extractAndImportImages(ctx context.Context, content *string, chapterIndex int) (err error) {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(*content))
-	raven.CheckError(err)
+	if err != nil {
+		return err
+	}
 
-	doc.Find("img[src]").Each(func(i int, selection *goquery.Selection) {
-		w.importValidMimeTypeFiles(content, chapterIndex, selection, "src")
+	doc.Find("img[src]").EachWithBreak(func(i int, selection *goquery.Selection) bool {
+		err = w.importValidMimeTypeFiles(ctx, content, chapterIndex, selection, "src")
+		return err == nil
 	})
+	if err != nil {
+		return err
+	}
 
-	doc.Find("[href]").Each(func(i int, selection *goquery.Selection) {
-		w.importValidMimeTypeFiles(content, chapterIndex, selection, "href")
+	doc.Find("[href]").EachWithBreak(func(i int, selection *goquery.Selection) bool {
+		err = w.importValidMimeTypeFiles(ctx, content, chapterIndex, selection, "href")
+		return err == nil
 	})
+	return err
 }
 
 // importValidMimeTypeFiles checks for the mime type by the file extension
 // and imports them in case they match the allowed mime types for epub files which are documented here:
 // https://www.w3.org/publishing/epub/epub-spec.html#sec-cmt-supported
-func (w *Writer) importValidMimeTypeFiles(content *string, index int, selection *goquery.Selection, attrName string) {
+func (w *Writer) importValidMimeTypeFiles(
+	ctx context.Context, content *string, index int, selection *goquery.Selection, attrName string,
+) error {
 	link, _ := selection.Attr(attrName)
 	switch mime.TypeByExtension(filepath.Ext(link)) {
 	case "image/gif", "image/jpeg", "image/png", "image/svg+xml":
 		log.Debugf("importing external resource %s for chapter index %d", link, index)
-		w.applyRateLimit()
+		if err := w.applyRateLimit(ctx); err != nil {
+			log.Debugf("run got interrupted, keeping external resource %s of chapter index %d", link, index)
+			return nil
+		}
 
 		// retrieve the outer HTML for later replacement
 		tag, err := goquery.OuterHtml(selection)
-		raven.CheckError(err)
+		if err != nil {
+			return err
+		}
 
 		// generate a unique file name and import the img source into the epub
 		filename := fmt.Sprintf("%d_%s_%d%s",
@@ -246,25 +436,36 @@ func (w *Writer) importValidMimeTypeFiles(content *string, index int, selection
 			rand.Int(),
 			filepath.Ext(link),
 		)
-		internalName, err := w.Epub.AddImage(link, filename)
-		raven.CheckError(err)
+		internalName, err := w.importImage(ctx, link, filename)
+		if err != nil && ctx.Err() != nil {
+			log.Debugf("run got interrupted, keeping external resource %s of chapter index %d", link, index)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to import image %s: %w", link, err)
+		}
 
 		// update the src to our new internal file name
 		selection.SetAttr(attrName, internalName)
 		updatedTag, err := goquery.OuterHtml(selection)
-		raven.CheckError(err)
+		if err != nil {
+			return err
+		}
 
 		// update our content
 		*content = strings.ReplaceAll(*content, tag, updatedTag)
 	}
+	return nil
 }
 
 // applyRateLimit waits for the leaky bucket to fill again
-func (w *Writer) applyRateLimit() {
+// returns the error of the context if the context got canceled while waiting
+func (w *Writer) applyRateLimit(ctx context.Context) error {
 	// if no rate limiter is defined we don't have to wait
-	if w.RateLimiter != nil {
+	// images downloaded through the session already use the rate limit of their host
+	if w.RateLimiter != nil && w.session == nil {
 		// wait for request to stay within the rate limit
-		err := w.RateLimiter.Wait(w.ctx)
-		raven.CheckError(err)
+		return w.RateLimiter.Wait(ctx)
 	}
+	return nil
 }
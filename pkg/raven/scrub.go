@@ -0,0 +1,65 @@
+package raven
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// replacement of the scrubbed values
+const filteredValue = "[filtered]"
+
+var (
+	// query strings of URLs, which can contain tokens or other personal data
+	urlQueryPattern = regexp.MustCompile(`(https?://[^\s?#"']+)\?[^\s#"']*`)
+	// values of cookie headers mentioned in error messages
+	cookieHeaderPattern = regexp.MustCompile(`(?i)((?:set-)?cookie:\s*)[^\r\n]*`)
+	// headers which are never sent
+	sensitiveHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+)
+
+// scrubEvent removes query strings and cookies from the passed event before sending it to sentry
+func scrubEvent(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+	event.Message = scrubText(event.Message)
+	for i := range event.Exception {
+		event.Exception[i].Value = scrubText(event.Exception[i].Value)
+	}
+	for _, breadcrumb := range event.Breadcrumbs {
+		breadcrumb.Message = scrubText(breadcrumb.Message)
+		for key, value := range breadcrumb.Data {
+			if text, ok := value.(string); ok {
+				breadcrumb.Data[key] = scrubText(text)
+			}
+		}
+	}
+	if event.Request != nil {
+		event.Request.URL = scrubText(event.Request.URL)
+		event.Request.QueryString = ""
+		event.Request.Cookies = ""
+		for name := range event.Request.Headers {
+			if isSensitiveHeader(name) {
+				event.Request.Headers[name] = filteredValue
+			}
+		}
+	}
+	// the host name of the machine isn't required to reproduce errors
+	event.ServerName = ""
+	return event
+}
+
+// scrubText removes the query strings of all URLs and the values of cookie headers from the passed text
+func scrubText(text string) string {
+	text = urlQueryPattern.ReplaceAllString(text, "$1")
+	return cookieHeaderPattern.ReplaceAllString(text, "${1}"+filteredValue)
+}
+
+// isSensitiveHeader checks if the passed header can contain cookies or credentials
+func isSensitiveHeader(name string) bool {
+	for _, header := range sensitiveHeaders {
+		if http.CanonicalHeaderKey(name) == header {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package raven
+
+import (
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+)
+
+func TestScrubText(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"no urls or cookies", "no urls or cookies"},
+		{"https://www.example.com/chapter-1", "https://www.example.com/chapter-1"},
+		{
+			`Get "https://www.example.com/chapter-1?token=secret&user=me": EOF`,
+			`Get "https://www.example.com/chapter-1": EOF`,
+		},
+		{
+			`unable to fetch "http://www.example.com/login?session=abc#top"`,
+			`unable to fetch "http://www.example.com/login#top"`,
+		},
+		{
+			"https://a.example.com/?a=1 and https://b.example.com/?b=2",
+			"https://a.example.com/ and https://b.example.com/",
+		},
+		{"Cookie: session=secret; user=me\nnext line", "Cookie: [filtered]\nnext line"},
+		{"set-cookie: session=secret", "set-cookie: [filtered]"},
+	}
+	for _, test := range tests {
+		if actual := scrubText(test.text); actual != test.expected {
+			t.Errorf("expected %q for %q, got %q", test.expected, test.text, actual)
+		}
+	}
+}
+
+func TestScrubEvent(t *testing.T) {
+	event := &sentry.Event{
+		Message:    "Get https://www.example.com/chapter-1?token=secret",
+		ServerName: "machine",
+		Exception:  []sentry.Exception{{Value: "Cookie: session=secret"}},
+		Breadcrumbs: []*sentry.Breadcrumb{{
+			Message: "https://www.example.com/?page=2",
+			Data:    map[string]interface{}{"url": "https://www.example.com/?page=3", "status": 200},
+		}},
+		Request: &sentry.Request{
+			URL:         "https://www.example.com/chapter-1?token=secret",
+			QueryString: "token=secret",
+			Cookies:     "session=secret",
+			Headers:     map[string]string{"cookie": "session=secret", "Accept": "text/html"},
+		},
+	}
+	event = scrubEvent(event, nil)
+	if event.Message != "Get https://www.example.com/chapter-1" || event.Exception[0].Value != "Cookie: [filtered]" {
+		t.Errorf("expected scrubbed message and exception, got %q and %q", event.Message, event.Exception[0].Value)
+	}
+	breadcrumb := event.Breadcrumbs[0]
+	if breadcrumb.Message != "https://www.example.com/" || breadcrumb.Data["url"] != "https://www.example.com/" ||
+		breadcrumb.Data["status"] != 200 {
+		t.Errorf("expected scrubbed breadcrumb, got %q and %v", breadcrumb.Message, breadcrumb.Data)
+	}
+	request := event.Request
+	if request.URL != "https://www.example.com/chapter-1" || request.QueryString != "" || request.Cookies != "" {
+		t.Errorf("expected scrubbed request, got %+v", request)
+	}
+	if request.Headers["cookie"] != filteredValue || request.Headers["Accept"] != "text/html" {
+		t.Errorf("expected only the cookie header to be filtered, got %v", request.Headers)
+	}
+	if event.ServerName != "" {
+		t.Errorf("expected removed server name, got %q", event.ServerName)
+	}
+}
@@ -4,28 +4,51 @@ import (
 	"io"
 	"time"
 
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
 	"github.com/DaRealFreak/epub-scraper/pkg/version"
 	"github.com/getsentry/sentry-go"
 	log "github.com/sirupsen/logrus"
 )
 
-// SetupSentry initializes the sentry
-func SetupSentry() {
+// DSN of the project, used if telemetry is enabled without an own DSN
+const defaultDSN = "https://b1eeaec0dada4b79bb8eb22be47a048a@sentry.io/1568260"
+
+// enabled is only set after sentry got initialized, so errors are never sent with disabled telemetry
+var enabled bool
+
+// SetupSentry initializes the sentry if the telemetry is enabled
+// without enabled telemetry sentry is not initialized at all and no errors are sent
+func SetupSentry(telemetry config.Telemetry) error {
+	if !telemetry.Enabled {
+		log.Debug("telemetry is disabled, errors are not reported")
+		return nil
+	}
+
+	dsn := telemetry.DSN
+	if dsn == "" {
+		dsn = defaultDSN
+	}
 	if err := sentry.Init(sentry.ClientOptions{
-		Dsn:     "https://b1eeaec0dada4b79bb8eb22be47a048a@sentry.io/1568260",
-		Release: "epub-scraper@" + version.VERSION,
+		Dsn:        dsn,
+		Release:    "epub-scraper@" + version.VERSION,
+		BeforeSend: scrubEvent,
 	}); err != nil {
-		log.Fatal(err)
+		return err
 	}
+	enabled = true
+	log.Debug("telemetry is enabled, errors are reported to sentry")
+	return nil
 }
 
-// CheckError checks if the passed error is not nil and passes it to the sentry DSN
+// CheckError checks if the passed error is not nil and passes it to the sentry DSN if the telemetry is enabled
 func CheckError(err error) {
 	if err != nil {
-		sentry.CaptureException(err)
-		// Since sentry emits events in the background we need to make sure
-		// they are sent before we shut down
-		sentry.Flush(time.Second * 5)
+		if enabled {
+			sentry.CaptureException(err)
+			// Since sentry emits events in the background we need to make sure
+			// they are sent before we shut down
+			sentry.Flush(time.Second * 5)
+		}
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,85 @@
+package scaffold
+
+// layout contains the known selectors of a common website layout
+type layout struct {
+	name string
+	// selector identifying the layout on the table of content page
+	detectSelector string
+	// selector to the chapter links on the table of content page
+	chapterSelector string
+	// the layout lists the newest chapters first
+	reversePosts     bool
+	nextPageSelector string
+	// selectors for the general information of the novel on the table of content page
+	titleSelector       string
+	authorSelector      string
+	descriptionSelector string
+	coverSelector       string
+	// preferred candidates for the title and content selectors on the chapter pages
+	chapterTitleCandidates   []string
+	chapterContentCandidates []string
+}
+
+// knownLayouts are the detectable layouts ordered from the most to the least specific detection selector
+var knownLayouts = []layout{
+	{
+		name:                "novelupdates",
+		detectSelector:      "table#myTable",
+		chapterSelector:     "table#myTable > tbody > tr > td > a.chp-release[href]",
+		reversePosts:        true,
+		nextPageSelector:    "div.w-blog-content div.digg_pagination > a.next_page[href]",
+		titleSelector:       "div.seriestitlenu",
+		authorSelector:      "div#showauthors > a",
+		descriptionSelector: "div#editdescription",
+		coverSelector:       "div.seriesimg > img[src]",
+	},
+	{
+		name:                     "madara",
+		detectSelector:           "li.wp-manga-chapter",
+		chapterSelector:          "li.wp-manga-chapter > a[href]",
+		reversePosts:             true,
+		titleSelector:            "div.post-title > h1",
+		authorSelector:           "div.author-content > a",
+		descriptionSelector:      "div.summary__content",
+		coverSelector:            "div.summary_image img[src]",
+		chapterTitleCandidates:   []string{"ol.breadcrumb > li.active", "h1#chapter-heading"},
+		chapterContentCandidates: []string{"div.reading-content div.text-left", "div.reading-content"},
+	},
+	{
+		name:                     "blogspot",
+		detectSelector:           "div.post-body",
+		chapterSelector:          "div.post-body a[href]",
+		titleSelector:            "h3.post-title",
+		chapterTitleCandidates:   []string{"h3.post-title"},
+		chapterContentCandidates: []string{"div.post-body"},
+	},
+	{
+		name:                     "wordpress",
+		detectSelector:           "article .entry-content",
+		chapterSelector:          "article .entry-content a[href]",
+		titleSelector:            "article .entry-title",
+		chapterTitleCandidates:   []string{"article .entry-title", "article .entry-content h1, article .entry-content h2"},
+		chapterContentCandidates: []string{"article .entry-content"},
+	},
+}
+
+// genericTitleCandidates are title selectors commonly used independent of the layout
+var genericTitleCandidates = []string{
+	".entry-title",
+	".post-title",
+	".chapter-title",
+	"h1",
+	"h2",
+	"h3",
+}
+
+// genericContentCandidates are content selectors commonly used independent of the layout
+var genericContentCandidates = []string{
+	"div.entry-content",
+	"div.chapter-content",
+	"div#chapter-content",
+	"div.post-content",
+	"div.text-content",
+	"article",
+	"main",
+}
@@ -0,0 +1,221 @@
+// Package scaffold generates novel configuration skeletons from the table of content page of a novel
+package scaffold
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+	"github.com/DaRealFreak/epub-scraper/pkg/session"
+	"github.com/PuerkitoBio/goquery"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Scaffolder detects the layout of table of content pages and chapter pages to generate configuration skeletons
+type Scaffolder struct {
+	session session.Session
+}
+
+// page is a retrieved and parsed page with the final URL after possible redirects
+type page struct {
+	url *url.URL
+	doc *goquery.Document
+}
+
+// NewScaffolder returns a new scaffolder struct
+func NewScaffolder() (*Scaffolder, error) {
+	// the skeleton should always be generated from the current state of the pages
+	scaffoldSession, err := session.NewSession(&config.NovelConfig{Cache: config.Cache{Disabled: true}})
+	if err != nil {
+		return nil, err
+	}
+	return &Scaffolder{session: scaffoldSession}, nil
+}
+
+// Generate returns the YAML skeleton of a novel configuration for the passed table of content URL
+// the skeleton contains the general, sites and chapters sections and is meant to be refined manually
+func (s *Scaffolder) Generate(ctx context.Context, tocURL string) ([]byte, error) {
+	toc, err := s.openPage(ctx, tocURL)
+	if err != nil {
+		return nil, err
+	}
+
+	tocLayout := detectLayout(toc.doc)
+	if tocLayout == nil {
+		return nil, fmt.Errorf("unable to detect a known table of content layout on %s", tocURL)
+	}
+	log.Infof("detected %s layout on %s", tocLayout.name, tocURL)
+
+	chapterURL, err := s.getFirstChapterURL(toc, tocLayout)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("analyzing chapter %s", chapterURL)
+	chapter, err := s.openPage(ctx, chapterURL)
+	if err != nil {
+		return nil, err
+	}
+
+	titleCandidates, contentCandidates := s.getCandidates(tocLayout, toc.url.Host == chapter.url.Host)
+	titleSelector := detectTitleSelector(chapter.doc, titleCandidates)
+	contentSelector := detectContentSelector(chapter.doc, contentCandidates)
+	if titleSelector == "" || contentSelector == "" {
+		log.Warningf("unable to detect all selectors on %s, please set them manually", chapter.url.String())
+	}
+
+	return yaml.Marshal(yaml.MapSlice{
+		{Key: "general", Value: s.getGeneral(toc, tocLayout)},
+		{Key: "sites", Value: s.getSites(toc, tocLayout, chapter, titleSelector, contentSelector)},
+		{Key: "chapters", Value: []yaml.MapSlice{{
+			{Key: "toc", Value: yaml.MapSlice{
+				{Key: "url", Value: tocURL},
+				{Key: "chapter-selector", Value: tocLayout.chapterSelector},
+			}},
+		}}},
+	})
+}
+
+// openPage retrieves the passed URL and parses the response
+func (s *Scaffolder) openPage(ctx context.Context, uri string) (*page, error) {
+	res, err := s.session.Get(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := s.session.GetDocument(res)
+	if err != nil {
+		return nil, err
+	}
+	return &page{url: res.Request.URL, doc: doc}, nil
+}
+
+// detectLayout returns the first known layout detected on the passed document or nil if no layout got detected
+func detectLayout(doc *goquery.Document) *layout {
+	for i := range knownLayouts {
+		if doc.Find(knownLayouts[i].detectSelector).Length() > 0 {
+			return &knownLayouts[i]
+		}
+	}
+	return nil
+}
+
+// getFirstChapterURL returns the absolute URL of the first chapter listed on the table of content page
+// for layouts listing the newest chapters first the last listed chapter link is used
+func (s *Scaffolder) getFirstChapterURL(toc *page, tocLayout *layout) (string, error) {
+	links := toc.doc.Find(tocLayout.chapterSelector)
+	if links.Length() == 0 {
+		return "", fmt.Errorf("no chapter links found on %s", toc.url.String())
+	}
+	link := links.First()
+	if tocLayout.reversePosts {
+		link = links.Last()
+	}
+	return s.resolveURL(toc.url, link.AttrOr("href", ""))
+}
+
+// resolveURL resolves the passed reference relative to the passed base URL
+func (s *Scaffolder) resolveURL(base *url.URL, reference string) (string, error) {
+	u, err := url.Parse(reference)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(u).String(), nil
+}
+
+// getCandidates returns the title and content selector candidates for chapter pages ordered by their priority
+// the candidates of the table of content layout are preferred if the chapters are hosted on the same host
+func (s *Scaffolder) getCandidates(tocLayout *layout, sameHost bool) (titleCandidates []string, contentCandidates []string) {
+	if sameHost {
+		titleCandidates = append(titleCandidates, tocLayout.chapterTitleCandidates...)
+		contentCandidates = append(contentCandidates, tocLayout.chapterContentCandidates...)
+	}
+	for _, knownLayout := range knownLayouts {
+		titleCandidates = append(titleCandidates, knownLayout.chapterTitleCandidates...)
+		contentCandidates = append(contentCandidates, knownLayout.chapterContentCandidates...)
+	}
+	return append(titleCandidates, genericTitleCandidates...), append(contentCandidates, genericContentCandidates...)
+}
+
+// getGeneral returns the general section with the novel information found on the table of content page
+// the OpenGraph meta tags are used as fallback for unknown or missing elements
+func (s *Scaffolder) getGeneral(toc *page, tocLayout *layout) yaml.MapSlice {
+	title := s.getText(toc.doc, tocLayout.titleSelector)
+	if title == "" {
+		title = toc.doc.Find(`meta[property="og:title"]`).AttrOr("content", "")
+	}
+	if title == "" {
+		title = strings.TrimSpace(toc.doc.Find("title").First().Text())
+	}
+
+	description := s.getText(toc.doc, tocLayout.descriptionSelector)
+	if description == "" {
+		description = toc.doc.Find(`meta[property="og:description"]`).AttrOr("content", "")
+	}
+
+	cover := ""
+	if tocLayout.coverSelector != "" {
+		cover = toc.doc.Find(tocLayout.coverSelector).First().AttrOr("src", "")
+	}
+	if cover == "" {
+		cover = toc.doc.Find(`meta[property="og:image"]`).AttrOr("content", "")
+	}
+	if cover != "" {
+		if resolvedCover, err := s.resolveURL(toc.url, cover); err == nil {
+			cover = resolvedCover
+		}
+	}
+
+	return yaml.MapSlice{
+		{Key: "title", Value: title},
+		{Key: "author", Value: s.getText(toc.doc, tocLayout.authorSelector)},
+		{Key: "description", Value: description},
+		{Key: "cover", Value: cover},
+		{Key: "language", Value: "english"},
+	}
+}
+
+// getText returns the trimmed text of the first element matching the passed selector
+func (s *Scaffolder) getText(doc *goquery.Document, selector string) string {
+	if selector == "" {
+		return ""
+	}
+	return strings.TrimSpace(doc.Find(selector).First().Text())
+}
+
+// getSites returns the site configurations for the table of content host and the chapter host
+// both are merged into one site configuration if the chapters are hosted on the same host as the table of content
+func (s *Scaffolder) getSites(
+	toc *page, tocLayout *layout, chapter *page, titleSelector string, contentSelector string,
+) (sites []yaml.MapSlice) {
+	var pagination yaml.MapSlice
+	if tocLayout.reversePosts {
+		pagination = append(pagination, yaml.MapItem{Key: "reverse-posts", Value: true})
+	}
+	if tocLayout.nextPageSelector != "" {
+		pagination = append(pagination, yaml.MapItem{Key: "next-page-selector", Value: tocLayout.nextPageSelector})
+	}
+
+	chapterSite := yaml.MapSlice{{Key: "host", Value: chapter.url.Host}}
+	if toc.url.Host != chapter.url.Host {
+		tocSite := yaml.MapSlice{{Key: "host", Value: toc.url.Host}}
+		if pagination != nil {
+			tocSite = append(tocSite, yaml.MapItem{Key: "pagination", Value: pagination})
+		}
+		sites = append(sites, tocSite)
+	} else if pagination != nil {
+		chapterSite = append(chapterSite, yaml.MapItem{Key: "pagination", Value: pagination})
+	}
+
+	chapterSite = append(chapterSite,
+		yaml.MapItem{Key: "title-content", Value: yaml.MapSlice{
+			{Key: "add-prefix", Value: false},
+			{Key: "title-selector", Value: titleSelector},
+		}},
+		yaml.MapItem{Key: "chapter-content", Value: yaml.MapSlice{
+			{Key: "content-selector", Value: contentSelector},
+		}},
+	)
+	return append(sites, chapterSite)
+}
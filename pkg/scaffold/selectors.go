@@ -0,0 +1,74 @@
+package scaffold
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maximum length of text to still be considered as a chapter title
+const maxTitleLength = 150
+
+var chapterTitlePattern = regexp.MustCompile(`(?i)(chapter|episode|part|prologue|epilogue|\d)`)
+
+// scoreTitleCandidate returns the score of the passed title selector candidate on the chapter page
+// candidates matching multiple elements, no text or too much text are not usable and score 0
+// the priority of the candidate is used as base score, titles looking like chapter titles score higher
+func scoreTitleCandidate(doc *goquery.Document, selector string, priority int) int {
+	selection := doc.Find(selector)
+	if selection.Length() != 1 {
+		return 0
+	}
+	text := strings.TrimSpace(selection.Text())
+	if text == "" || len(text) > maxTitleLength {
+		return 0
+	}
+	score := priority
+	if chapterTitlePattern.MatchString(text) {
+		score += priority
+	}
+	return score
+}
+
+// scoreContentCandidate returns the score of the passed content selector candidate on the chapter page
+// the score is the amount of paragraph text not being part of links, so navigation and link lists score low
+func scoreContentCandidate(doc *goquery.Document, selector string) int {
+	selection := doc.Find(selector).First()
+	if selection.Length() == 0 {
+		return 0
+	}
+	paragraphText := 0
+	selection.Find("p").Each(func(i int, paragraph *goquery.Selection) {
+		paragraphText += len(strings.TrimSpace(paragraph.Text()))
+	})
+	linkText := 0
+	selection.Find("a").Each(func(i int, link *goquery.Selection) {
+		linkText += len(strings.TrimSpace(link.Text()))
+	})
+	return paragraphText - linkText
+}
+
+// detectTitleSelector returns the best scoring title selector of the passed candidates
+func detectTitleSelector(doc *goquery.Document, candidates []string) string {
+	bestSelector, bestScore := "", 0
+	for i, candidate := range candidates {
+		if score := scoreTitleCandidate(doc, candidate, len(candidates)-i); score > bestScore {
+			bestSelector, bestScore = candidate, score
+		}
+	}
+	return bestSelector
+}
+
+// detectContentSelector returns the best scoring content selector of the passed candidates
+// candidates are ordered by their priority, later candidates have to score noticeably higher to get selected
+// since generic candidates like "article" often also contain comments or related posts
+func detectContentSelector(doc *goquery.Document, candidates []string) string {
+	bestSelector, bestScore := "", 0
+	for _, candidate := range candidates {
+		if score := scoreContentCandidate(doc, candidate); score > bestScore+bestScore/5 {
+			bestSelector, bestScore = candidate, score
+		}
+	}
+	return bestSelector
+}
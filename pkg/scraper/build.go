@@ -0,0 +1,187 @@
+package scraper
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+	"github.com/DaRealFreak/epub-scraper/pkg/epub"
+	"github.com/DaRealFreak/epub-scraper/pkg/session"
+	log "github.com/sirupsen/logrus"
+)
+
+// BookWriter is the interface of the writer the extracted chapters are added to, implemented by the epub.Writer
+type BookWriter interface {
+	AddChapter(ctx context.Context, title string, content string, addPrefix bool, volume string) error
+	MarkIncomplete()
+	GetFileName() string
+	WriteEpubTo(output io.Writer, polish bool) error
+}
+
+// Result contains the outcome of building a novel
+type Result struct {
+	// extracted chapters in the order they got added to the epub, skipped chapters are not included
+	Chapters []*ChapterData
+	// absolute path of the written epub, empty if the epub got written into the output of the options or not at all
+	FileName string
+	// the run got interrupted before all configured chapters got extracted
+	Incomplete bool
+}
+
+// Build extracts the chapters of the passed novel configuration and writes the epub into the output of the options
+// or into <title>.epub in the working directory if no output is set
+// after the context got canceled the already extracted chapters are returned together with the error of the context
+func Build(ctx context.Context, cfg *config.NovelConfig, scraperOptions Options) (*Result, error) {
+	scraper, err := NewScraper(scraperOptions)
+	if err != nil {
+		return nil, err
+	}
+	return scraper.Build(ctx, cfg)
+}
+
+// Build extracts the chapters of the passed novel configuration and writes the epub
+// novels built without a configuration file have no state file and no checkpoint, so all chapters are extracted
+// configurations created in Go are prepared like read configuration files before building the novel
+func (s *Scraper) Build(ctx context.Context, cfg *config.NovelConfig) (*Result, error) {
+	if err := s.configParser.PrepareConfiguration(cfg); err != nil {
+		return nil, err
+	}
+	s.state = newNovelState("")
+	s.checkpoint = newCheckpoint("")
+	return s.build(ctx, cfg)
+}
+
+// build extracts the configured chapters with the loaded state and checkpoint and writes them into the epub
+func (s *Scraper) build(ctx context.Context, cfg *config.NovelConfig) (result *Result, err error) {
+	if err = s.applyOptions(cfg); err != nil {
+		return nil, err
+	}
+	if s.options.Session != nil {
+		s.session = s.options.Session
+	} else {
+		if s.session, err = session.NewSession(cfg); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if closeErr := s.session.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+	}
+	if s.emojis, err = newEmojiSanitizer(ctx, cfg.Emojis, s.session); err != nil {
+		return nil, err
+	}
+	// the emoji mode falls back to strip without emoji names, chapters are saved with the actually used mode
+	cfg.Emojis.Mode = s.emojis.mode
+
+	result = &Result{}
+	err = s.buildNovel(ctx, cfg, result)
+	s.logReplacementReport(cfg)
+	s.logRevalidationReport()
+	return result, err
+}
+
+// buildNovel extracts the configured chapters and writes them into the epub
+func (s *Scraper) buildNovel(ctx context.Context, cfg *config.NovelConfig, result *Result) (err error) {
+	writer := s.options.Writer
+	if writer == nil {
+		if writer, err = epub.NewWriter(ctx, cfg, s.session, s.observer); err != nil {
+			return err
+		}
+	}
+
+	volume := ""
+	for index, source := range cfg.Chapters {
+		if ctx.Err() != nil {
+			break
+		}
+		s.checkpoint.source = index
+		var chapters []*ChapterData
+		if source.Toc != nil {
+			if chapters, err = s.handleToc(ctx, source.Toc, cfg); err != nil {
+				return err
+			}
+		} else if source.Chapter != nil {
+			s.observer.ChapterLinkDiscovered(source.Chapter.URL)
+			chapter, err := s.getChapterData(ctx, source.Chapter.URL, cfg, source.Chapter.SourceContent)
+			if err != nil {
+				return err
+			}
+			if chapter != nil {
+				chapters = append(chapters, chapter)
+			}
+		}
+		for _, chapter := range chapters {
+			volume = s.getChapterVolume(cfg, source, chapter.Title, volume)
+			if err = writer.AddChapter(ctx, chapter.Title, chapter.Content, chapter.AddPrefix, volume); err != nil {
+				return err
+			}
+			result.Chapters = append(result.Chapters, chapter)
+		}
+	}
+	if ctx.Err() != nil {
+		result.Incomplete = true
+		return s.handleInterruptedRun(ctx, writer, result)
+	}
+
+	// finally save the generated epub
+	if err = s.writeOutput(writer, result); err != nil {
+		return err
+	}
+	// only save the state after successfully writing the epub to extract failed chapters again on the next run
+	if err = s.state.save(); err != nil {
+		return err
+	}
+	return s.checkpoint.remove()
+}
+
+// handleInterruptedRun writes the already added chapters of the interrupted run as incomplete epub if enabled
+// the checkpoint is kept to be able to resume the run later on, returns the error of the canceled context
+func (s *Scraper) handleInterruptedRun(ctx context.Context, writer BookWriter, result *Result) error {
+	if s.checkpoint.directory != "" {
+		log.Warningf("run got interrupted, use --resume to continue it from the checkpoint %s", s.checkpoint.directory)
+	}
+	if s.options.Partial {
+		writer.MarkIncomplete()
+		if err := s.writeOutput(writer, result); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// writeOutput writes the epub into the output of the options or into the working directory if no output is set
+func (s *Scraper) writeOutput(writer BookWriter, result *Result) error {
+	polish := !s.options.SkipPolish
+	if s.options.Output != nil {
+		if err := writer.WriteEpubTo(s.options.Output, polish); err != nil {
+			return err
+		}
+		s.observer.EpubWritten("")
+		return nil
+	}
+
+	path, err := filepath.Abs(filepath.Clean(writer.GetFileName()))
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = writer.WriteEpubTo(file, polish); err != nil {
+		_ = file.Close()
+		// don't leave an incomplete file behind
+		_ = os.Remove(path)
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	result.FileName = path
+	log.Infof("epub saved to %s", path)
+	s.observer.EpubWritten(path)
+	return nil
+}
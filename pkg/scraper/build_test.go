@@ -0,0 +1,208 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+// recordingWriter is a BookWriter only recording the added chapters
+type recordingWriter struct {
+	titles     []string
+	volumes    []string
+	incomplete bool
+}
+
+func (w *recordingWriter) AddChapter(
+	_ context.Context, title string, content string, addPrefix bool, volume string,
+) error {
+	w.titles = append(w.titles, title)
+	w.volumes = append(w.volumes, volume)
+	return nil
+}
+
+func (w *recordingWriter) MarkIncomplete() {
+	w.incomplete = true
+}
+
+func (w *recordingWriter) GetFileName() string {
+	return "Novel.epub"
+}
+
+func (w *recordingWriter) WriteEpubTo(output io.Writer, polish bool) error {
+	_, err := fmt.Fprintf(output, "%d chapter(s), polished: %t", len(w.titles), polish)
+	return err
+}
+
+// recordingObserver records the events of a build, the chapter events can be sent concurrently
+type recordingObserver struct {
+	NopObserver
+	mutex      sync.Mutex
+	tocPages   []string
+	discovered []string
+	redirects  []string
+	extracted  []string
+	written    []string
+}
+
+func (o *recordingObserver) TocPageFetched(tocURL string) {
+	o.tocPages = append(o.tocPages, tocURL)
+}
+
+func (o *recordingObserver) ChapterLinkDiscovered(chapterURL string) {
+	o.discovered = append(o.discovered, chapterURL)
+}
+
+func (o *recordingObserver) RedirectFollowed(fromURL string, toURL string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.redirects = append(o.redirects, fromURL+" -> "+toURL)
+}
+
+func (o *recordingObserver) ChapterExtracted(chapterURL string, _ *ChapterData) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.extracted = append(o.extracted, chapterURL)
+}
+
+func (o *recordingObserver) EpubWritten(fileName string) {
+	o.written = append(o.written, fileName)
+}
+
+// readBuildTestConfiguration returns the configuration of a novel with a table of contents and a single chapter
+// served by a new test server
+func readBuildTestConfiguration(t *testing.T) *config.NovelConfig {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/toc":
+			_, _ = fmt.Fprint(w, `<a class="chapter" href="/chapter-1">1</a><a class="chapter" href="/chapter-2">2</a>`)
+		case "/moved":
+			http.Redirect(w, r, "/epilogue", http.StatusFound)
+		default:
+			_, _ = fmt.Fprintf(w, `<h1>Title of %s</h1><div class="content"><p>content</p></div>`, r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readTestConfiguration(t, fmt.Sprintf(`general:
+  title: Novel
+cache:
+  disabled: true
+sites:
+  - host: %s
+    rate-limit: 1ms
+    burst: 10
+    title-content:
+      title-selector: h1
+    chapter-content:
+      content-selector: div.content
+chapters:
+  - toc:
+      url: %s/toc
+      chapter-selector: a.chapter
+    volume: Volume 1
+  - chapter:
+      url: %s/moved
+`, serverURL.Host, server.URL, server.URL))
+}
+
+func TestBuildWithInjectedWriter(t *testing.T) {
+	cfg := readBuildTestConfiguration(t)
+	s := newTestScraper(t, cfg)
+	writer := &recordingWriter{}
+	output := &bytes.Buffer{}
+	s.options = Options{Writer: writer, Output: output, Session: s.session}
+
+	result, err := s.Build(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTitles := []string{"Title of /chapter-1", "Title of /chapter-2", "Title of /epilogue"}
+	if len(result.Chapters) != len(expectedTitles) || len(writer.titles) != len(expectedTitles) {
+		t.Fatalf(
+			"expected %d chapters, got %d in the result and %d in the writer",
+			len(expectedTitles), len(result.Chapters), len(writer.titles),
+		)
+	}
+	for i, expected := range expectedTitles {
+		if result.Chapters[i].Title != expected || writer.titles[i] != expected {
+			t.Errorf("expected %s at position %d, got %s and %s", expected, i, result.Chapters[i].Title, writer.titles[i])
+		}
+	}
+	if writer.volumes[1] != "Volume 1" {
+		t.Errorf("expected chapters of the table of contents in the volume of the source, got %q", writer.volumes[1])
+	}
+	// the final URL of redirected chapters is kept next to the configured URL
+	epilogue := result.Chapters[2]
+	sourceURL := cfg.Chapters[1].Chapter.URL
+	if epilogue.SourceURL != sourceURL || epilogue.FinalURL != strings.TrimSuffix(sourceURL, "/moved")+"/epilogue" {
+		t.Errorf("unexpected source URL %s and final URL %s", epilogue.SourceURL, epilogue.FinalURL)
+	}
+	if output.String() != "3 chapter(s), polished: true" || result.FileName != "" || result.Incomplete {
+		t.Errorf("unexpected output %q and result %+v", output.String(), result)
+	}
+}
+
+func TestBuildWithOutput(t *testing.T) {
+	cfg := readBuildTestConfiguration(t)
+	s := newTestScraper(t, cfg)
+	output := &bytes.Buffer{}
+	s.options = Options{Output: output, SkipPolish: true}
+
+	result, err := s.Build(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// epub files are zip archives starting with the uncompressed mimetype file
+	if !bytes.HasPrefix(output.Bytes(), []byte("PK")) || !bytes.Contains(output.Bytes(), []byte("application/epub+zip")) {
+		t.Errorf("expected epub written into the output, got %d byte(s)", output.Len())
+	}
+	if len(result.Chapters) != 3 || result.FileName != "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestBuildObserver(t *testing.T) {
+	cfg := readBuildTestConfiguration(t)
+	s := newTestScraper(t, cfg)
+	observer := &recordingObserver{}
+	s.observer = observer
+	s.options = Options{Writer: &recordingWriter{}, Output: &bytes.Buffer{}, Session: s.session}
+
+	if _, err := s.Build(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	tocURL := cfg.Chapters[0].Toc.URL
+	movedURL := cfg.Chapters[1].Chapter.URL
+	if len(observer.tocPages) != 1 || observer.tocPages[0] != tocURL {
+		t.Errorf("expected the table of contents page %s, got %v", tocURL, observer.tocPages)
+	}
+	if len(observer.discovered) != 3 || observer.discovered[2] != movedURL {
+		t.Errorf("expected 3 discovered chapter URLs ending with %s, got %v", movedURL, observer.discovered)
+	}
+	expectedRedirect := movedURL + " -> " + strings.TrimSuffix(movedURL, "/moved") + "/epilogue"
+	if len(observer.redirects) != 1 || observer.redirects[0] != expectedRedirect {
+		t.Errorf("expected redirect %s, got %v", expectedRedirect, observer.redirects)
+	}
+	if len(observer.extracted) != 3 {
+		t.Errorf("expected 3 extracted chapters, got %v", observer.extracted)
+	}
+	// the file name is empty since the epub got written into the output
+	if len(observer.written) != 1 || observer.written[0] != "" {
+		t.Errorf("expected a single written epub without file name, got %v", observer.written)
+	}
+}
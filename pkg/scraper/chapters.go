@@ -1,35 +1,43 @@
 package scraper
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
 
 	"github.com/DaRealFreak/epub-scraper/pkg/config"
-	"github.com/DaRealFreak/epub-scraper/pkg/raven"
 	"github.com/PuerkitoBio/goquery"
 	log "github.com/sirupsen/logrus"
 )
 
 // extractChapterData follows the redirects from the URL and the configuration
 // and extracts the chapter title/content from the final URL
+// returns the error of failed requests, f.e. after the context got canceled
 func (s *Scraper) extractChapterData(
-	chapterURL string, cfg *config.NovelConfig, srcCfg config.SourceContent,
-) (chapterData *ChapterData) {
+	ctx context.Context, chapterURL string, cfg *config.NovelConfig, srcCfg config.SourceContent,
+) (chapterData *ChapterData, err error) {
 	chapterURL, _ = cfg.DoURLReplacements(chapterURL)
 	// directly return nil if initial URL is blacklisted
 	if cfg.IsURLBlacklisted(chapterURL) {
-		return nil
+		return nil, nil
 	}
 
 	// open chapter URL and retrieve chapter content
-	res, chapterURL, doc, siteConfig, srcCfg := s.openChapterURL(chapterURL, cfg, srcCfg)
+	res, chapterURL, doc, siteConfig, srcCfg, err := s.openChapterURL(ctx, chapterURL, cfg, srcCfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// follow replacement and retrieve new chapter content
 	replacementURL, changed := cfg.DoURLReplacements(chapterURL)
 	if changed {
-		res, chapterURL, doc, siteConfig, srcCfg = s.openChapterURL(replacementURL, cfg, srcCfg)
+		res, chapterURL, doc, siteConfig, srcCfg, err = s.openChapterURL(ctx, replacementURL, cfg, srcCfg)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// if we have redirects resolve them
@@ -40,7 +48,9 @@ func (s *Scraper) extractChapterData(
 			redirectLink, exists := doc.Find(redirect).First().Attr("href")
 			// handle eventual relative URLs
 			redirectURL, err := url.Parse(redirectLink)
-			raven.CheckError(err)
+			if err != nil {
+				return nil, err
+			}
 			redirectLink = res.Request.URL.ResolveReference(redirectURL).String()
 			// no redirect found use the URL from before
 			if !exists {
@@ -52,110 +62,168 @@ func (s *Scraper) extractChapterData(
 				break
 			}
 			// request the found redirect link and update the document we will use for the chapter extraction
-			res, err = s.session.Get(redirectLink)
-			raven.CheckError(err)
-			doc = s.session.GetDocument(res)
+			fromURL := res.Request.URL.String()
+			res, err = s.session.Get(ctx, redirectLink)
+			if err != nil {
+				return nil, err
+			}
+			if doc, err = s.session.GetDocument(res); err != nil {
+				return nil, err
+			}
 			log.Debugf("got redirected to url: %s", res.Request.URL.String())
-			// break in case we got redirected to a different host
-			if res.Request.Host != siteConfig.Host {
+			s.observer.RedirectFollowed(fromURL, res.Request.URL.String())
+			// break in case we got redirected to a URL of a different site configuration
+			if !siteConfig.MatchesURL(res.Request.URL) {
 				srcCfg = cfg.GetSiteConfigFromURL(res.Request.URL).SourceContent
 				break
 			}
 		}
-		// if the redirected URL has a different host resolve the redirects from the new host too
+		// if the redirected URL has a different site configuration resolve the redirects from the new site too
 		parsedURL, err := url.Parse(chapterURL)
-		raven.CheckError(err)
-		if parsedURL.Host != siteConfig.Host {
+		if err != nil {
+			return nil, err
+		}
+		if !siteConfig.MatchesURL(parsedURL) {
 			// update configuration to match the new host
 			srcCfg = siteConfig.SourceContent
-			return s.extractChapterData(chapterURL, cfg, srcCfg)
+			return s.extractChapterData(ctx, chapterURL, cfg, srcCfg)
 		}
 	}
 	finalChapterURL := res.Request.URL.String()
 	if cfg.IsURLBlacklisted(finalChapterURL) {
-		return nil
+		return nil, nil
 	}
 	log.Infof("extracting chapter from %s", finalChapterURL)
+	title, err := s.getChapterTitle(doc, &srcCfg.TitleContent)
+	if err != nil {
+		return nil, fmt.Errorf("unable to extract chapter title from %s: %w", finalChapterURL, err)
+	}
+	if cfg.IsTitleBlacklisted(title) {
+		return nil, nil
+	}
+	content, err := s.getChapterContent(doc, &srcCfg.ChapterContent)
+	if err != nil {
+		return nil, fmt.Errorf("unable to extract chapter content from %s: %w", finalChapterURL, err)
+	}
 	chapterData = &ChapterData{
-		addPrefix: *srcCfg.TitleContent.AddPrefix,
-		title:     s.getChapterTitle(doc, &srcCfg.TitleContent),
-		content:   s.getChapterContent(doc, &srcCfg.ChapterContent),
+		FinalURL:  finalChapterURL,
+		Title:     title,
+		Content:   content,
+		AddPrefix: srcCfg.TitleContent.AddPrefix != nil && *srcCfg.TitleContent.AddPrefix,
 	}
-	log.Infof("extracted chapter: %s (content length: %d)", chapterData.title, len(chapterData.content))
-	return chapterData
+	log.Infof("extracted chapter: %s (content length: %d)", chapterData.Title, len(chapterData.Content))
+	return chapterData, nil
 }
 
-func (s *Scraper) openChapterURL(chapterURL string, cfg *config.NovelConfig, srcCfg config.SourceContent) (
-	*http.Response, string, *goquery.Document, *config.SiteConfiguration, config.SourceContent,
-) {
-	res, err := s.session.Get(chapterURL)
-	raven.CheckError(err)
-	doc := s.session.GetDocument(res)
+func (s *Scraper) openChapterURL(
+	ctx context.Context, chapterURL string, cfg *config.NovelConfig, srcCfg config.SourceContent,
+) (*http.Response, string, *goquery.Document, *config.SiteConfiguration, config.SourceContent, error) {
+	res, err := s.session.Get(ctx, chapterURL)
+	if err != nil {
+		return nil, "", nil, nil, srcCfg, err
+	}
+	doc, err := s.session.GetDocument(res)
+	if err != nil {
+		return nil, "", nil, nil, srcCfg, err
+	}
 	// follow redirects for f.e. exit links from novelupdates
 	// retrieve site config for the host of the chapter url
 	siteConfig := cfg.GetSiteConfigFromURL(res.Request.URL)
 	if !s.isURLEqual(chapterURL, res.Request.URL.String()) {
 		// update configuration to match the new host
+		s.observer.RedirectFollowed(chapterURL, res.Request.URL.String())
 		chapterURL = res.Request.URL.String()
 		srcCfg = siteConfig.SourceContent
 		log.Debugf("got redirected to url: %s", chapterURL)
 	}
 
-	return res, chapterURL, doc, siteConfig, srcCfg
+	return res, chapterURL, doc, siteConfig, srcCfg, nil
 }
 
 // getChapterContent returns the chapter content of the passed URL based on the passed ChapterContent settings
-func (s *Scraper) getChapterContent(doc *goquery.Document, content *config.ChapterContent) string {
-	chapterContent, err := doc.Find(*content.ContentSelector).First().Html()
-	raven.CheckError(err)
+func (s *Scraper) getChapterContent(doc *goquery.Document, content *config.ChapterContent) (string, error) {
+	var chapterContent string
+	var err error
+	if content.ContentSelector == nil || *content.ContentSelector == "" {
+		// no content selector configured, detect the main content of the page automatically
+		log.Debug("no content selector configured, detecting chapter content")
+		chapterContent, err = s.detectContent(doc)
+	} else {
+		chapterContent, err = doc.Find(*content.ContentSelector).First().Html()
+	}
+	if err != nil {
+		return "", err
+	}
 
-	chapterContent = s.applyCleanupOptions(chapterContent, &content.CleanupOptions, "Content")
+	chapterContent, err = s.applyCleanupOptions(chapterContent, &content.CleanupOptions, "Content")
+	if err != nil {
+		return "", err
+	}
 
-	return s.sanitizer.StripUnicodeEmojis(s.fixHTMLCode(chapterContent))
+	chapterContent, err = s.fixHTMLCode(chapterContent)
+	if err != nil {
+		return "", err
+	}
+	return s.emojis.sanitizeContent(chapterContent), nil
 }
 
 // getChapterTitle returns the chapter title of the passed URL based on the passed ChapterContent settings
-func (s *Scraper) getChapterTitle(doc *goquery.Document, content *config.TitleContent) string {
+func (s *Scraper) getChapterTitle(doc *goquery.Document, content *config.TitleContent) (string, error) {
 	// if we only use the prefix the title selector can be nil too
 	if content.TitleSelector == nil {
-		return ""
+		return "", nil
 	}
 
 	titleContent, err := doc.Find(*content.TitleSelector).First().Html()
-	raven.CheckError(err)
+	if err != nil {
+		return "", err
+	}
 
-	titleContent = s.applyCleanupOptions(titleContent, &content.CleanupOptions, "Title")
+	titleContent, err = s.applyCleanupOptions(titleContent, &content.CleanupOptions, "Title")
+	if err != nil {
+		return "", err
+	}
 
 	doc, err = goquery.NewDocumentFromReader(strings.NewReader(titleContent))
-	raven.CheckError(err)
+	if err != nil {
+		return "", err
+	}
 
-	return strings.TrimSpace(s.sanitizer.StripUnicodeEmojis(doc.Text()))
+	return strings.TrimSpace(s.emojis.sanitizeTitle(doc.Text())), nil
 }
 
 // removePrefix removes the author block of the extracted chapter content based on the selector
-func (s *Scraper) removePrefix(chapterContent string, selector string) string {
+func (s *Scraper) removePrefix(chapterContent string, selector string) (string, error) {
 	contentDoc, err := goquery.NewDocumentFromReader(strings.NewReader(chapterContent))
-	raven.CheckError(err)
+	if err != nil {
+		return "", err
+	}
 	selection := contentDoc.Find(selector).First()
 	if selection.Length() > 0 {
 		afterAuthor, err := goquery.OuterHtml(selection)
-		raven.CheckError(err)
+		if err != nil {
+			return "", err
+		}
 		chapterContent = strings.Join(strings.Split(chapterContent, afterAuthor)[1:], "")
 	}
-	return chapterContent
+	return chapterContent, nil
 }
 
 // removeSuffix removes the footer block of the extracted chapter content based on the selector
-func (s *Scraper) removeSuffix(chapterContent string, selector string) string {
+func (s *Scraper) removeSuffix(chapterContent string, selector string) (string, error) {
 	contentDoc, err := goquery.NewDocumentFromReader(strings.NewReader(chapterContent))
-	raven.CheckError(err)
+	if err != nil {
+		return "", err
+	}
 	selection := contentDoc.Find(selector).First()
 	if selection.Length() > 0 {
 		afterFooter, err := goquery.OuterHtml(selection)
-		raven.CheckError(err)
+		if err != nil {
+			return "", err
+		}
 		chapterContent = strings.Split(chapterContent, afterFooter)[0]
 	}
-	return chapterContent
+	return chapterContent, nil
 }
 
 // sanitizeSpaces replaces NBSP with normal spaces (0x20) since f.e. regex \s doesn't match with NBSP (0xA0)
@@ -171,27 +239,36 @@ func (s *Scraper) sanitizeSpaces(content string) string {
 }
 
 // applyCleanupOptions applies the cleanup options to the passed html before returning it again
-func (s *Scraper) applyCleanupOptions(htmlContent string, options *config.CleanupOptions, captureGroup string) string {
+func (s *Scraper) applyCleanupOptions(
+	htmlContent string, options *config.CleanupOptions, captureGroup string,
+) (_ string, err error) {
 	// strip unicode emojis from the title and trim the text before parsing with the regular expressions
 	htmlContent = s.sanitizeSpaces(htmlContent)
 
 	// ToDo: use document.Find(sel).First().NextAll() instead of ripping apart the HTML
 	if options.PrefixSelectors != nil {
 		for _, prefixSelector := range *options.PrefixSelectors {
-			htmlContent = s.removePrefix(htmlContent, prefixSelector)
+			if htmlContent, err = s.removePrefix(htmlContent, prefixSelector); err != nil {
+				return "", err
+			}
 		}
 	}
 
 	if options.SuffixSelectors != nil {
 		for _, suffixSelector := range *options.SuffixSelectors {
-			htmlContent = s.removeSuffix(htmlContent, suffixSelector)
+			if htmlContent, err = s.removeSuffix(htmlContent, suffixSelector); err != nil {
+				return "", err
+			}
 		}
 	}
 
 	// strip title with regular expressions if set in the related configuration
 	// (for f.e. additional notes or we have to select title from main content)
 	if options.StripRegex != "" {
-		re := regexp.MustCompile(options.StripRegex)
+		re, err := regexp.Compile(options.StripRegex)
+		if err != nil {
+			return "", &config.RegexError{Pattern: options.StripRegex, Err: err}
+		}
 		matches := re.FindStringSubmatch(htmlContent)
 
 		paramsMap := make(map[string]string)
@@ -201,20 +278,31 @@ func (s *Scraper) applyCleanupOptions(htmlContent string, options *config.Cleanu
 			}
 		}
 
-		if val, ok := paramsMap[captureGroup]; ok {
-			htmlContent = val
-		} else {
-			log.Fatalf("capture group %s is required for the title cleanup pattern", captureGroup)
+		val, ok := paramsMap[captureGroup]
+		if !ok {
+			return "", &config.RegexError{
+				Pattern: options.StripRegex,
+				Err:     fmt.Errorf("capture group %s is required for the cleanup pattern", captureGroup),
+			}
 		}
+		htmlContent = val
 	}
 
 	// clean up content with regular expressions if set in the related configuration (for f.e. translator notes)
 	if options.CleanupRegex != "" {
-		re := regexp.MustCompile(options.CleanupRegex)
+		re, err := regexp.Compile(options.CleanupRegex)
+		if err != nil {
+			return "", &config.RegexError{Pattern: options.CleanupRegex, Err: err}
+		}
 		htmlContent = re.ReplaceAllString(htmlContent, "")
 	}
 
-	return htmlContent
+	// apply the transformation pipeline in the declared order
+	if options.Transforms != nil {
+		return s.applyTransforms(htmlContent, *options.Transforms)
+	}
+
+	return htmlContent, nil
 }
 
 // isURLEqual compares the passed URLs for equality ignoring scheme differences
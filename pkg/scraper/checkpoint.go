@@ -0,0 +1,158 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// checkpoint persists every extracted chapter directly after the extraction
+// to be able to resume interrupted runs without extracting the already extracted chapters again
+type checkpoint struct {
+	directory string
+	// index of the currently handled source of the chapters section
+	source int
+	// amount of already saved chapters by their source index
+	positions map[int]int
+	// chapters of the interrupted run by their source index and URL
+	chapters map[int]map[string]*chapterState
+	// chapters are added concurrently by the extraction workers
+	mutex sync.Mutex
+}
+
+// getCheckpointDirectory returns the checkpoint directory of the passed configuration file
+func getCheckpointDirectory(fileName string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".checkpoint"
+}
+
+// newCheckpoint returns an empty checkpoint saved in the passed directory, checkpoints without a directory are not saved
+func newCheckpoint(directory string) *checkpoint {
+	return &checkpoint{
+		directory: directory,
+		positions: make(map[int]int),
+		chapters:  make(map[int]map[string]*chapterState),
+	}
+}
+
+// loadCheckpoint loads the checkpoint of the passed configuration file if we are resuming the previous run
+// checkpoints of previous runs are only removed if explicitly discarded to never lose already extracted chapters
+func (s *Scraper) loadCheckpoint(fileName string) (*checkpoint, error) {
+	c := newCheckpoint(getCheckpointDirectory(fileName))
+	if s.options.Resume && s.options.DiscardCheckpoint {
+		return nil, fmt.Errorf("the checkpoint can't be resumed and discarded at the same time")
+	}
+
+	// the checkpoint directory is created on saving the first chapter
+	if _, err := os.Stat(c.directory); os.IsNotExist(err) {
+		return c, nil
+	}
+	if s.options.DiscardCheckpoint {
+		log.Warningf("removing checkpoint of interrupted run in %s", c.directory)
+		return c, os.RemoveAll(c.directory)
+	}
+	if !s.options.Resume {
+		return nil, fmt.Errorf(
+			"found checkpoint of interrupted run in %s, use --resume to continue it or --discard-checkpoint to start again",
+			c.directory,
+		)
+	}
+
+	sourceDirectories, err := ioutil.ReadDir(c.directory)
+	if err != nil {
+		return nil, err
+	}
+	resumedChapters := 0
+	for _, sourceDirectory := range sourceDirectories {
+		var source int
+		if _, err = fmt.Sscanf(sourceDirectory.Name(), "source-%d", &source); err != nil || !sourceDirectory.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(c.directory, sourceDirectory.Name()))
+		if err != nil {
+			return nil, err
+		}
+		c.chapters[source] = make(map[string]*chapterState)
+		for _, file := range files {
+			var position int
+			// temporary files of chapters which got interrupted while saving them are ignored
+			if _, err = fmt.Sscanf(file.Name(), "%06d.json", &position); err != nil || filepath.Ext(file.Name()) != ".json" {
+				continue
+			}
+			chapter, err := c.readChapter(filepath.Join(c.directory, sourceDirectory.Name(), file.Name()))
+			if err != nil {
+				log.Warningf("unable to read checkpoint file %s: %s", file.Name(), err.Error())
+				continue
+			}
+			c.chapters[source][chapter.URL] = chapter
+			c.positions[source] = position + 1
+			resumedChapters++
+		}
+	}
+	log.Infof("resuming interrupted run with %d already extracted chapter(s) from %s", resumedChapters, c.directory)
+	return c, nil
+}
+
+// readChapter reads a single chapter of the checkpoint
+func (c *checkpoint) readChapter(fileName string) (*chapterState, error) {
+	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		return nil, err
+	}
+	chapter := &chapterState{}
+	if err = json.Unmarshal(content, chapter); err != nil {
+		return nil, err
+	}
+	if !chapter.Skipped && chapter.ContentHash != getContentHash(chapter.Content) {
+		return nil, fmt.Errorf("content hash of %s doesn't match", chapter.URL)
+	}
+	return chapter, nil
+}
+
+// get returns the chapter of the interrupted run in the current source
+func (c *checkpoint) get(chapterURL string) (*chapterState, bool) {
+	chapter, exists := c.chapters[c.source][chapterURL]
+	return chapter, exists
+}
+
+// add saves the passed extracted chapter at the next position of the current source
+// the chapter is written into a temporary file first to not leave incomplete chapters on interruptions
+func (c *checkpoint) add(chapter *chapterState) error {
+	if c.directory == "" {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	sourceDirectory := filepath.Join(c.directory, fmt.Sprintf("source-%04d", c.source))
+	if err := os.MkdirAll(sourceDirectory, 0700); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(chapter)
+	if err != nil {
+		return err
+	}
+	fileName := filepath.Join(sourceDirectory, fmt.Sprintf("%06d.json", c.positions[c.source]))
+	if err = ioutil.WriteFile(fileName+".tmp", content, 0600); err != nil {
+		return err
+	}
+	if err = os.Rename(fileName+".tmp", fileName); err != nil {
+		return err
+	}
+	c.positions[c.source]++
+	return nil
+}
+
+// remove removes the checkpoint after the epub got written successfully
+func (c *checkpoint) remove() error {
+	if c.directory == "" {
+		return nil
+	}
+	return os.RemoveAll(c.directory)
+}
@@ -0,0 +1,102 @@
+package scraper
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	ctx := context.Background()
+	fileName := filepath.Join(t.TempDir(), "novel.yaml")
+	s := &Scraper{observer: NopObserver{}}
+	c, err := s.loadCheckpoint(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for source, chapters := range [][]*chapterState{
+		{
+			newTestChapterState("https://www.example.com/chapter-1", &ChapterData{Title: "Chapter 1", Content: "<p>1</p>"}),
+			newTestChapterState("https://www.example.com/teaser", nil),
+		},
+		{
+			newTestChapterState("https://www.example.com/side-story", &ChapterData{Title: "Side Story", Content: "<p>s</p>"}),
+			newTestChapterState("https://www.example.com/chapter-2", &ChapterData{Title: "Chapter 2", Content: "<p>2</p>"}),
+		},
+	} {
+		c.source = source
+		for _, chapter := range chapters {
+			if err = c.add(chapter); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	sourceDirectory := filepath.Join(c.directory, "source-0001")
+	// chapters with modified content and interrupted writes are extracted again
+	if err = ioutil.WriteFile(filepath.Join(sourceDirectory, "000001.json"), []byte(
+		`{"url":"https://www.example.com/chapter-2","content":"modified","content-hash":"invalid"}`,
+	), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(sourceDirectory, "000002.json.tmp"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s.options.Resume = true
+	s.checkpoint, err = s.loadCheckpoint(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.state = &novelState{chapters: make(map[string]*chapterState)}
+	// the scraper has no session, so the chapters have to be returned from the checkpoint
+	cfg := &config.NovelConfig{}
+	chapter, err := s.getChapterData(ctx, "https://www.example.com/chapter-1", cfg, config.SourceContent{})
+	if err != nil || chapter == nil || chapter.Title != "Chapter 1" || chapter.Content != "<p>1</p>" {
+		t.Errorf("expected chapter 1 from the checkpoint, got %+v", chapter)
+	}
+	chapter, err = s.getChapterData(ctx, "https://www.example.com/teaser", cfg, config.SourceContent{})
+	if err != nil || chapter != nil {
+		t.Errorf("expected skipped chapter from the checkpoint, got %+v", chapter)
+	}
+	if _, exists := s.checkpoint.get("https://www.example.com/side-story"); exists {
+		t.Errorf("expected chapters of other sources to be not used in the current source")
+	}
+
+	s.checkpoint.source = 1
+	if _, exists := s.checkpoint.get("https://www.example.com/side-story"); !exists {
+		t.Errorf("expected side story in the checkpoint of the second source")
+	}
+	if _, exists := s.checkpoint.get("https://www.example.com/chapter-2"); exists {
+		t.Errorf("expected chapter with modified content to be extracted again")
+	}
+	// new chapters of the source are added after the last resumed chapter
+	if s.checkpoint.positions[1] != 1 {
+		t.Errorf("expected next position 1 in the second source, got %d", s.checkpoint.positions[1])
+	}
+
+	// checkpoints are kept if the run is neither resumed nor discarded
+	s.options.Resume = false
+	if _, err = s.loadCheckpoint(fileName); err == nil {
+		t.Errorf("expected error for existing checkpoint without resuming or discarding it")
+	}
+	s.options.Resume, s.options.DiscardCheckpoint = true, true
+	if _, err = s.loadCheckpoint(fileName); err == nil {
+		t.Errorf("expected error for resuming and discarding the checkpoint at the same time")
+	}
+	if _, err = os.Stat(c.directory); err != nil {
+		t.Fatalf("expected kept checkpoint directory, got %v", err)
+	}
+
+	// checkpoints are only removed if explicitly discarded
+	s.options.Resume = false
+	if c, err = s.loadCheckpoint(fileName); err != nil || len(c.chapters) != 0 {
+		t.Fatalf("expected empty discarded checkpoint, got %d source(s) and error %v", len(c.chapters), err)
+	}
+	if _, err = os.Stat(c.directory); !os.IsNotExist(err) {
+		t.Errorf("expected removed checkpoint directory, got %v", err)
+	}
+}
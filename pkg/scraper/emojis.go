@@ -0,0 +1,445 @@
+package scraper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+	"github.com/DaRealFreak/epub-scraper/pkg/session"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// directory of the emoji data in the cache directory of the user
+	emojiDirectoryName = "epub-scraper/emojis"
+	// file containing the code points of all emojis, used to strip the emojis
+	emojiDataFileName = "emoji-data.txt"
+	// file containing all emoji sequences with their CLDR short names
+	emojiTestFileName = "emoji-test.txt"
+)
+
+// allow common emojis implemented everywhere: "#", "*", "[0-9]", "©", "®", "‼", "™"
+var defaultAllowedEmojiCodes = []string{"0023", "002A", "0030..0039", "00A9", "00AE", "203C", "2122"}
+
+//go:generate go run emojis_data_gen.go
+
+// emojiDataLinePattern matches the emoji code or the emoji code range of a line of the emoji-data.txt file
+var emojiDataLinePattern = regexp.MustCompile(`^([0-9A-F]{4,6}(?:\.\.[0-9A-F]{4,6})?)\s*;`)
+
+// emojiTestLinePattern matches the emoji sequence and the CLDR short name of a line of the emoji-test.txt file,
+// the emoji version in front of the short name only exists in newer versions
+var emojiTestLinePattern = regexp.MustCompile(`^([0-9A-F ]+?)\s*;\s*[\w-]+\s*#\s*\S+\s+(?:E\d+\.\d+\s+)?(.+)$`)
+
+// emojiSanitizer strips the emojis of the chapter titles and contents or replaces them depending on the emoji mode
+type emojiSanitizer struct {
+	mode     string
+	imageURL string
+	// merged code point ranges of all emojis sorted by their first code point
+	emojis  []emojiCodeRange
+	allowed []emojiCodeRange
+	// CLDR short names of all emoji sequences, only loaded for the short-name and the image mode
+	names map[string]string
+	// runes starting any emoji sequence to skip the lookup of the sequences for all other runes
+	firstRunes map[rune]bool
+	// amount of runes of the longest emoji sequence
+	maxSequenceLength int
+}
+
+// emojiCodeRange is a single code or a range of codes of the allow list
+type emojiCodeRange struct {
+	from rune
+	to   rune
+}
+
+// newEmojiSanitizer loads the emoji data of the configured unicode version from the emoji data directory
+// or uses the bundled emoji data if the directory doesn't contain it, only the emoji names are downloaded
+// once into the emoji data directory with the passed session unless the offline mode is set
+func newEmojiSanitizer(
+	ctx context.Context, emojis config.Emojis, emojiSession session.Session,
+) (*emojiSanitizer, error) {
+	s := &emojiSanitizer{
+		mode:     emojis.GetMode(),
+		imageURL: emojis.GetImageURL(),
+	}
+	for _, code := range append(append([]string{}, defaultAllowedEmojiCodes...), emojis.Allow...) {
+		codeRange, err := parseEmojiCodeRange(code)
+		if err != nil {
+			return nil, err
+		}
+		s.allowed = append(s.allowed, codeRange)
+	}
+
+	// the names are checked before loading the emoji data to fail early on unicode versions without names
+	var testURL string
+	if s.mode != config.EmojiModeStrip {
+		var err error
+		if testURL, err = getEmojiTestURL(emojis.GetUnicodeVersion()); err != nil {
+			return nil, err
+		}
+	}
+
+	directory, err := getEmojiDirectory(emojis)
+	if err != nil {
+		return nil, err
+	}
+	if s.emojis, err = loadEmojiRanges(directory, emojis.GetUnicodeVersion()); err != nil {
+		return nil, err
+	}
+
+	if s.mode == config.EmojiModeStrip {
+		return s, nil
+	}
+
+	testFile, err := getEmojiTestFile(ctx, emojiSession, directory, testURL, emojis.Offline)
+	if err != nil {
+		return nil, err
+	}
+	if testFile == "" {
+		log.Warningf(
+			"emoji names of unicode %s weren't downloaded yet and the emoji offline mode is set, stripping emojis instead",
+			emojis.GetUnicodeVersion(),
+		)
+		s.mode = config.EmojiModeStrip
+		return s, nil
+	}
+	if err = s.loadNames(testFile); err != nil {
+		return nil, fmt.Errorf("unable to load emoji names from %s: %w", testFile, err)
+	}
+	return s, nil
+}
+
+// getEmojiDirectory returns the directory of the emoji data of the configured unicode version
+func getEmojiDirectory(emojis config.Emojis) (string, error) {
+	directory := emojis.Directory
+	if directory == "" {
+		userCacheDirectory, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		directory = filepath.Join(userCacheDirectory, filepath.FromSlash(emojiDirectoryName))
+	}
+	return filepath.Join(directory, emojis.GetUnicodeVersion()), nil
+}
+
+// loadEmojiRanges returns the emoji ranges of the emoji-data.txt file in the passed emoji data directory
+// or the bundled emoji ranges of the passed unicode version if the directory doesn't contain an emoji-data.txt file
+func loadEmojiRanges(directory string, version string) ([]emojiCodeRange, error) {
+	fileName := filepath.Join(directory, emojiDataFileName)
+	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+	if os.IsNotExist(err) {
+		log.Debugf("no emoji data found in %s, using the bundled emoji data of unicode %s", fileName, version)
+		return bundledEmojiRanges[version], nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	emojiRanges, err := parseEmojiData(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load emoji data from %s: %w", fileName, err)
+	}
+	return emojiRanges, nil
+}
+
+// parseEmojiData returns the merged code point ranges of all entries of the passed emoji-data.txt content
+func parseEmojiData(content string) (emojiRanges []emojiCodeRange, err error) {
+	for _, line := range strings.Split(content, "\n") {
+		matches := emojiDataLinePattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		codeRange, err := parseEmojiCodeRange(matches[1])
+		if err != nil {
+			return nil, err
+		}
+		emojiRanges = append(emojiRanges, codeRange)
+	}
+	if len(emojiRanges) == 0 {
+		return nil, fmt.Errorf("no emoji codes found")
+	}
+	return mergeEmojiCodeRanges(emojiRanges), nil
+}
+
+// mergeEmojiCodeRanges sorts the passed ranges by their first code point and merges overlapping and adjacent ranges
+func mergeEmojiCodeRanges(emojiRanges []emojiCodeRange) (merged []emojiCodeRange) {
+	sort.Slice(emojiRanges, func(i, j int) bool {
+		return emojiRanges[i].from < emojiRanges[j].from
+	})
+	for _, codeRange := range emojiRanges {
+		if last := len(merged) - 1; last >= 0 && codeRange.from <= merged[last].to+1 {
+			if codeRange.to > merged[last].to {
+				merged[last].to = codeRange.to
+			}
+			continue
+		}
+		merged = append(merged, codeRange)
+	}
+	return merged
+}
+
+// getEmojiTestURL returns the URL of the emoji-test.txt file of the passed unicode version,
+// the CLDR short names are only available for unicode 4.0 and later
+func getEmojiTestURL(version string) (string, error) {
+	if major, _ := strconv.Atoi(strings.Split(version, ".")[0]); major < 4 {
+		return "", fmt.Errorf("emoji names are only available for unicode 4.0 and later, not for unicode %s", version)
+	}
+	return fmt.Sprintf("https://unicode.org/Public/emoji/%s/emoji-test.txt", version), nil
+}
+
+// getEmojiTestFile returns the path of the emoji-test.txt file in the passed emoji data directory
+// and downloads the file from the passed URL with the passed session if it doesn't exist yet,
+// returns an empty path if the file doesn't exist yet and the offline mode is set
+func getEmojiTestFile(
+	ctx context.Context, emojiSession session.Session, directory string, fileURL string, offline bool,
+) (string, error) {
+	path := filepath.Join(directory, emojiTestFileName)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	if offline {
+		return "", nil
+	}
+
+	log.Infof("downloading emoji names from %s to %s", fileURL, path)
+	if err := downloadEmojiFile(ctx, emojiSession, fileURL, path); err != nil {
+		return "", fmt.Errorf("unable to download emoji names from %s: %w", fileURL, err)
+	}
+	return path, nil
+}
+
+// downloadEmojiFile downloads the passed URL into a temporary file and moves it to the passed path afterwards,
+// so interrupted downloads don't leave incomplete emoji data behind,
+// the download uses the session to apply the configured proxy and response cache
+func downloadEmojiFile(ctx context.Context, emojiSession session.Session, fileURL string, path string) error {
+	res, err := emojiSession.Get(ctx, fileURL)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, res.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+	return err
+}
+
+// parseEmojiCodeRange parses a single code like "2764" or a range of codes like "1F600..1F64F" of the allow list
+func parseEmojiCodeRange(code string) (emojiCodeRange, error) {
+	bounds := strings.SplitN(code, "..", 2)
+	from, err := strconv.ParseInt(bounds[0], 16, 32)
+	if err != nil {
+		return emojiCodeRange{}, fmt.Errorf("invalid emoji code %s: %w", code, err)
+	}
+	to := from
+	if len(bounds) == 2 {
+		if to, err = strconv.ParseInt(bounds[1], 16, 32); err != nil {
+			return emojiCodeRange{}, fmt.Errorf("invalid emoji code %s: %w", code, err)
+		}
+	}
+	return emojiCodeRange{from: rune(from), to: rune(to)}, nil
+}
+
+// loadNames loads the CLDR short names of all emoji sequences of the passed emoji-test.txt file
+func (s *emojiSanitizer) loadNames(fileName string) error {
+	file, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	s.names = make(map[string]string)
+	s.firstRunes = make(map[rune]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		matches := emojiTestLinePattern.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+		var sequence []rune
+		for _, code := range strings.Fields(matches[1]) {
+			codeRange, err := parseEmojiCodeRange(code)
+			if err != nil {
+				return err
+			}
+			sequence = append(sequence, codeRange.from)
+		}
+		if s.isSequenceAllowed(sequence) {
+			continue
+		}
+		s.names[string(sequence)] = strings.TrimSpace(matches[2])
+		s.firstRunes[sequence[0]] = true
+		if len(sequence) > s.maxSequenceLength {
+			s.maxSequenceLength = len(sequence)
+		}
+	}
+	return scanner.Err()
+}
+
+// isSequenceAllowed checks if all runes of the passed emoji sequence except for variation selectors are allowed
+func (s *emojiSanitizer) isSequenceAllowed(sequence []rune) bool {
+	for _, r := range sequence {
+		if r != '\uFE0F' && !s.isRuneAllowed(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isEmoji checks if the passed rune is in the emoji ranges of the emoji data
+func (s *emojiSanitizer) isEmoji(r rune) bool {
+	i := sort.Search(len(s.emojis), func(i int) bool {
+		return s.emojis[i].to >= r
+	})
+	return i < len(s.emojis) && s.emojis[i].from <= r
+}
+
+// isRuneAllowed checks if the passed rune is in the allow list
+func (s *emojiSanitizer) isRuneAllowed(r rune) bool {
+	for _, codeRange := range s.allowed {
+		if r >= codeRange.from && r <= codeRange.to {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeTitle strips the emojis of the passed title or replaces them with their short names
+// titles can't contain images, so the image mode uses the short names for titles too
+func (s *emojiSanitizer) sanitizeTitle(title string) string {
+	if s.mode != config.EmojiModeStrip {
+		title = s.replaceSequences(title, func(sequence string, name string, inTag bool) string {
+			return ":" + name + ":"
+		})
+	}
+	return s.stripEmojis(title)
+}
+
+// sanitizeContent strips the emojis of the passed HTML content or replaces them with their short names or images
+// emojis in attribute values are replaced with their short names in the image mode too
+func (s *emojiSanitizer) sanitizeContent(content string) string {
+	if s.mode != config.EmojiModeStrip {
+		content = s.replaceSequences(content, func(sequence string, name string, inTag bool) string {
+			if s.mode == config.EmojiModeImage && !inTag {
+				return fmt.Sprintf(
+					`<img src="%s" alt="%s"/>`,
+					html.EscapeString(strings.Replace(s.imageURL, "{code}", s.getImageCode(sequence), -1)),
+					html.EscapeString(":"+name+":"),
+				)
+			}
+			return html.EscapeString(":" + name + ":")
+		})
+	}
+	// remaining emojis without name like single skin tone modifiers are still stripped
+	return s.stripEmojis(content)
+}
+
+// stripEmojis strips all emoji runes which aren't in the allow list from the passed subject
+func (s *emojiSanitizer) stripEmojis(subject string) string {
+	return strings.Map(func(r rune) rune {
+		if s.isEmoji(r) && !s.isRuneAllowed(r) {
+			return -1
+		}
+		return r
+	}, subject)
+}
+
+// replaceSequences replaces the longest known emoji sequence at every position of the passed subject
+// the passed replace function also receives if the sequence is inside of a HTML tag
+// which is reliable since the content got rendered before and all "<" and ">" in text and attributes are escaped
+func (s *emojiSanitizer) replaceSequences(
+	subject string, replace func(sequence string, name string, inTag bool) string,
+) string {
+	var result strings.Builder
+	inTag := false
+	for i := 0; i < len(subject); {
+		if sequence, name := s.matchSequence(subject[i:]); sequence != "" {
+			result.WriteString(replace(sequence, name, inTag))
+			i += len(sequence)
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(subject[i:])
+		switch r {
+		case '<':
+			inTag = true
+		case '>':
+			inTag = false
+		}
+		result.WriteString(subject[i : i+size])
+		i += size
+	}
+	return result.String()
+}
+
+// matchSequence returns the longest known emoji sequence at the start of the passed subject and its short name
+func (s *emojiSanitizer) matchSequence(subject string) (sequence string, name string) {
+	r, _ := utf8.DecodeRuneInString(subject)
+	if !s.firstRunes[r] {
+		return "", ""
+	}
+	// byte offsets of the end of the first runes to check the longest sequences first
+	var ends []int
+	for i := range subject {
+		if i > 0 {
+			ends = append(ends, i)
+		}
+		if len(ends) == s.maxSequenceLength {
+			break
+		}
+	}
+	if len(ends) < s.maxSequenceLength {
+		ends = append(ends, len(subject))
+	}
+	for i := len(ends) - 1; i >= 0; i-- {
+		if name, ok := s.names[subject[:ends[i]]]; ok {
+			return subject[:ends[i]], name
+		}
+	}
+	return "", ""
+}
+
+// getImageCode returns the lowercase hex codes of the passed emoji sequence joined by "-" like twemoji names the images
+// variation selectors are only part of the name in sequences joined with zero width joiners
+func (s *emojiSanitizer) getImageCode(sequence string) string {
+	var codes []string
+	for _, r := range sequence {
+		if r == '\uFE0F' && !strings.ContainsRune(sequence, '\u200D') {
+			continue
+		}
+		codes = append(codes, strconv.FormatInt(int64(r), 16))
+	}
+	return strings.Join(codes, "-")
+}
@@ -0,0 +1,179 @@
+//go:build ignore
+// +build ignore
+
+// emojis_data_gen generates the bundled emoji ranges of emojis_data.go from the emoji-data.txt files of unicode.org
+// run "go generate ./pkg/scraper" to update them, use the source option to generate them from local files instead,
+// the source directory has to contain the emoji-data.txt file of every unicode version f.e. 12.1/emoji-data.txt
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+// maximum line length of the generated code with a tab width of 4
+const maxLineLength = 120
+
+// emojiDataLinePattern matches the emoji code or the emoji code range of a line of the emoji-data.txt file
+var emojiDataLinePattern = regexp.MustCompile(`^([0-9A-F]{4,6}(?:\.\.[0-9A-F]{4,6})?)\s*;`)
+
+// emojiCodeRange is a single code or a range of codes of the emoji data
+type emojiCodeRange struct {
+	from int64
+	to   int64
+}
+
+func main() {
+	source := flag.String("source", "", "directory containing the emoji-data.txt files, downloaded if empty")
+	output := flag.String("output", "emojis_data.go", "generated file")
+	flag.Parse()
+
+	var code bytes.Buffer
+	code.WriteString("// Code generated by emojis_data_gen.go; DO NOT EDIT.\n\npackage scraper\n\n")
+	code.WriteString("// bundledEmojiRanges are the merged code point ranges of all entries of the emoji-data.txt files " +
+		"by unicode version,\n// used if the emoji data directory doesn't contain an emoji-data.txt file, " +
+		"so stripping emojis never requires downloads\nvar bundledEmojiRanges = map[string][]emojiCodeRange{\n")
+	var sharedRanges []emojiCodeRange
+	for _, version := range config.EmojiVersions {
+		// the emoji data of unicode 13.1 is the same as the emoji data of unicode 13.0
+		if isUnicode13(version) {
+			if sharedRanges == nil {
+				if sharedRanges = readEmojiRanges(*source, version); len(sharedRanges) == 0 {
+					log.Fatalf("no emoji codes found for unicode %s", version)
+				}
+			}
+			continue
+		}
+		emojiRanges := readEmojiRanges(*source, version)
+		if len(emojiRanges) == 0 {
+			log.Fatalf("no emoji codes found for unicode %s", version)
+		}
+		fmt.Fprintf(&code, "\t%q: {\n", version)
+		writeEmojiRanges(&code, emojiRanges, 2)
+		code.WriteString("\t},\n")
+	}
+	code.WriteString("\t// the emoji data of unicode 13.1 is the same as the emoji data of unicode 13.0\n")
+	for _, version := range config.EmojiVersions {
+		if isUnicode13(version) {
+			fmt.Fprintf(&code, "\t%q: emojiRanges13,\n", version)
+		}
+	}
+	code.WriteString("}\n\n// emojiRanges13 are the merged code point ranges of the emoji-data.txt file " +
+		"of unicode 13.0 and 13.1\nvar emojiRanges13 = []emojiCodeRange{\n")
+	writeEmojiRanges(&code, sharedRanges, 1)
+	code.WriteString("}\n")
+
+	formatted, err := format.Source(code.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = ioutil.WriteFile(*output, formatted, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// isUnicode13 checks if the passed unicode version uses the emoji data of unicode 13.0
+func isUnicode13(version string) bool {
+	major, _ := strconv.Atoi(strings.Split(version, ".")[0])
+	return major >= 13
+}
+
+// readEmojiRanges returns the merged emoji ranges of the emoji-data.txt file of the passed unicode version
+func readEmojiRanges(source string, version string) []emojiCodeRange {
+	var (
+		content []byte
+		err     error
+	)
+	if source != "" {
+		content, err = ioutil.ReadFile(filepath.Join(source, version, "emoji-data.txt"))
+	} else {
+		content, err = download(version)
+	}
+	if err != nil {
+		log.Fatalf("unable to read the emoji data of unicode %s: %v", version, err)
+	}
+
+	var emojiRanges []emojiCodeRange
+	for _, line := range strings.Split(string(content), "\n") {
+		matches := emojiDataLinePattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		bounds := strings.SplitN(matches[1], "..", 2)
+		from, _ := strconv.ParseInt(bounds[0], 16, 32)
+		to := from
+		if len(bounds) == 2 {
+			to, _ = strconv.ParseInt(bounds[1], 16, 32)
+		}
+		emojiRanges = append(emojiRanges, emojiCodeRange{from: from, to: to})
+	}
+
+	// merge overlapping and adjacent ranges like the emoji sanitizer does for downloaded emoji data
+	sort.Slice(emojiRanges, func(i, j int) bool {
+		return emojiRanges[i].from < emojiRanges[j].from
+	})
+	var merged []emojiCodeRange
+	for _, codeRange := range emojiRanges {
+		if last := len(merged) - 1; last >= 0 && codeRange.from <= merged[last].to+1 {
+			if codeRange.to > merged[last].to {
+				merged[last].to = codeRange.to
+			}
+			continue
+		}
+		merged = append(merged, codeRange)
+	}
+	return merged
+}
+
+// download downloads the emoji-data.txt file of the passed unicode version from unicode.org,
+// the emoji data of unicode 13.0 and later is part of the unicode character database
+func download(version string) ([]byte, error) {
+	uri := fmt.Sprintf("https://unicode.org/Public/emoji/%s/emoji-data.txt", version)
+	if isUnicode13(version) {
+		uri = "https://unicode.org/Public/13.0.0/ucd/emoji/emoji-data.txt"
+	}
+	log.Printf("downloading emoji data of unicode %s from %s", version, uri)
+	// #nosec G107
+	res, err := http.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// writeEmojiRanges writes the passed ranges as composite literals with the passed indentation
+// and wraps the lines at the maximum line length
+func writeEmojiRanges(code *bytes.Buffer, emojiRanges []emojiCodeRange, indentation int) {
+	indent := strings.Repeat("\t", indentation)
+	line := indent
+	for _, codeRange := range emojiRanges {
+		entry := fmt.Sprintf("{0x%04X, 0x%04X},", codeRange.from, codeRange.to)
+		if line != indent && 4*indentation+len(line)-indentation+1+len(entry) > maxLineLength {
+			code.WriteString(line + "\n")
+			line = indent
+		}
+		if line != indent {
+			line += " "
+		}
+		line += entry
+	}
+	code.WriteString(line + "\n")
+}
@@ -0,0 +1,258 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+	"github.com/DaRealFreak/epub-scraper/pkg/session"
+)
+
+// directory of the emoji-test.txt excerpt used for the short-name and the image mode
+const testEmojiDirectory = "testdata/emojis"
+
+func TestSanitizeContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		emojis   config.Emojis
+		content  string
+		expected string
+	}{
+		{
+			name:     "strip",
+			content:  "<p>Hi 👋🏽, to the moon 🚀!</p>",
+			expected: "<p>Hi , to the moon !</p>",
+		},
+		{
+			name:     "strip keeps common emojis",
+			content:  "<p>Chapter #1 © 2020 ™ ‼</p>",
+			expected: "<p>Chapter #1 © 2020 ™ ‼</p>",
+		},
+		{
+			name:     "strip with allowed range",
+			emojis:   config.Emojis{Allow: []string{"1F600..1F64F", "2764"}},
+			content:  "<p>😀 ❤ 🚀</p>",
+			expected: "<p>😀 ❤ </p>",
+		},
+		{
+			name:     "short-name",
+			emojis:   config.Emojis{Mode: config.EmojiModeShortName},
+			content:  "<p>Hi 👋🏽 👋, ❤️‍🔥 ❤️ ❤</p>",
+			expected: "<p>Hi :waving hand: medium skin tone: :waving hand:, :heart on fire: :red heart: :red heart:</p>",
+		},
+		{
+			name:     "short-name strips emojis without name",
+			emojis:   config.Emojis{Mode: config.EmojiModeShortName},
+			content:  "<p>🛸 😀</p>",
+			expected: "<p> :grinning face:</p>",
+		},
+		{
+			name:     "short-name keeps allowed emojis",
+			emojis:   config.Emojis{Mode: config.EmojiModeShortName, Allow: []string{"1F600..1F64F"}},
+			content:  "<p>😀 🚀</p>",
+			expected: "<p>😀 :rocket:</p>",
+		},
+		{
+			name:    "image",
+			emojis:  config.Emojis{Mode: config.EmojiModeImage, ImageURL: "https://images.example.com/{code}.png"},
+			content: "<p>👨‍👩‍👧 ❤️</p>",
+			expected: `<p><img src="https://images.example.com/1f468-200d-1f469-200d-1f467.png" ` +
+				`alt=":family: man, woman, girl:"/> <img src="https://images.example.com/2764.png" alt=":red heart:"/></p>`,
+		},
+		{
+			name:     "image in attribute",
+			emojis:   config.Emojis{Mode: config.EmojiModeImage},
+			content:  `<p title="😀">text</p>`,
+			expected: `<p title=":grinning face:">text</p>`,
+		},
+	}
+
+	for _, test := range tests {
+		test.emojis.Directory = testEmojiDirectory
+		s, err := newEmojiSanitizer(context.Background(), test.emojis, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if actual := s.sanitizeContent(test.content); actual != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestSanitizeTitle(t *testing.T) {
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{config.EmojiModeStrip, "Chapter 1 - Liftoff "},
+		{config.EmojiModeShortName, "Chapter 1 - Liftoff :rocket:"},
+		// titles can't contain images, so the image mode uses the short names
+		{config.EmojiModeImage, "Chapter 1 - Liftoff :rocket:"},
+	}
+	for _, test := range tests {
+		s, err := newEmojiSanitizer(context.Background(), config.Emojis{Mode: test.mode, Directory: testEmojiDirectory}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := s.sanitizeTitle("Chapter 1 - Liftoff 🚀"); actual != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.mode, test.expected, actual)
+		}
+	}
+}
+
+func TestNewEmojiSanitizerOffline(t *testing.T) {
+	// missing emoji names fall back to stripping the emojis instead of downloading the names
+	s, err := newEmojiSanitizer(context.Background(), config.Emojis{
+		Mode:      config.EmojiModeShortName,
+		Offline:   true,
+		Directory: t.TempDir(),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.mode != config.EmojiModeStrip {
+		t.Errorf("expected fallback to the strip mode, got %s", s.mode)
+	}
+	if actual := s.sanitizeContent("<p>😀</p>"); actual != "<p></p>" {
+		t.Errorf("expected stripped emojis with the bundled emoji data, got %q", actual)
+	}
+
+	if _, err = newEmojiSanitizer(context.Background(), config.Emojis{
+		Mode:           config.EmojiModeShortName,
+		UnicodeVersion: "3.0",
+		Offline:        true,
+		Directory:      t.TempDir(),
+	}, nil); err == nil {
+		t.Errorf("expected error for emoji names of unicode versions before 4.0")
+	}
+}
+
+func TestGetEmojiTestFile(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/emoji-test.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprint(w, "1F600 ; fully-qualified # 😀 E1.0 grinning face\n")
+	}))
+	defer server.Close()
+
+	emojiSession, err := session.NewSession(readTestConfiguration(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	testURL := server.URL + "/emoji-test.txt"
+	directory := filepath.Join(t.TempDir(), config.DefaultEmojiVersion)
+	// missing emoji names are not downloaded in the offline mode
+	if path, err := getEmojiTestFile(ctx, emojiSession, directory, testURL, true); err != nil || path != "" {
+		t.Errorf("expected no emoji names in the offline mode, got %q and %v", path, err)
+	}
+	path, err := getEmojiTestFile(ctx, emojiSession, directory, testURL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(path); len(content) == 0 {
+		t.Errorf("expected downloaded emoji names at %s", path)
+	}
+	// existing emoji names are used without downloading them again, even in the offline mode
+	if path, err = getEmojiTestFile(ctx, emojiSession, directory, testURL, true); err != nil || path == "" {
+		t.Errorf("expected the downloaded emoji names, got %q and %v", path, err)
+	}
+	if requests != 1 {
+		t.Errorf("expected a single download, got %d request(s)", requests)
+	}
+
+	if _, err = getEmojiTestFile(ctx, emojiSession, t.TempDir(), server.URL+"/missing.txt", false); err == nil {
+		t.Errorf("expected error for failed download")
+	}
+}
+
+func TestLoadEmojiRanges(t *testing.T) {
+	for _, version := range config.EmojiVersions {
+		if len(bundledEmojiRanges[version]) == 0 {
+			t.Errorf("no bundled emoji data for unicode %s", version)
+		}
+	}
+
+	directory := t.TempDir()
+	emojiRanges, err := loadEmojiRanges(directory, config.DefaultEmojiVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(emojiRanges, bundledEmojiRanges[config.DefaultEmojiVersion]) {
+		t.Errorf("expected the bundled emoji data without emoji-data.txt file")
+	}
+
+	content := "# custom emoji data\n1F600..1F602 ; Emoji # grinning faces\n1F680 ; Emoji # rocket\n"
+	if err = ioutil.WriteFile(filepath.Join(directory, emojiDataFileName), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emojiRanges, err = loadEmojiRanges(directory, config.DefaultEmojiVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []emojiCodeRange{{0x1F600, 0x1F602}, {0x1F680, 0x1F680}}
+	if !reflect.DeepEqual(emojiRanges, expected) {
+		t.Errorf("expected the emoji data of the emoji-data.txt file %v, got %v", expected, emojiRanges)
+	}
+}
+
+func TestParseEmojiData(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expected    []emojiCodeRange
+		expectError bool
+	}{
+		{
+			name:     "merged ranges",
+			content:  "1F680 ; Emoji\n1F600..1F610 ; Emoji\n1F605..1F620 ; Emoji_Presentation\n1F621 ; Emoji\n00A9 ; Emoji\n",
+			expected: []emojiCodeRange{{0x00A9, 0x00A9}, {0x1F600, 0x1F621}, {0x1F680, 0x1F680}},
+		},
+		{
+			name:     "comments and properties",
+			content:  "# 1F600 ; Emoji\n\n1F3FB..1F3FF  ; Emoji_Modifier  # E1.0   [5] light skin tone\n",
+			expected: []emojiCodeRange{{0x1F3FB, 0x1F3FF}},
+		},
+		{name: "no emoji codes", content: "# no data\n", expectError: true},
+	}
+	for _, test := range tests {
+		actual, err := parseEmojiData(test.content)
+		if (err != nil) != test.expectError {
+			t.Errorf("%s: parseEmojiData returned error %v, expected error: %t", test.name, err, test.expectError)
+			continue
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestGetImageCode(t *testing.T) {
+	tests := []struct {
+		sequence string
+		expected string
+	}{
+		{"😀", "1f600"},
+		// variation selectors are only kept in sequences with zero width joiners
+		{"❤️", "2764"},
+		{"❤️‍🔥", "2764-fe0f-200d-1f525"},
+		{"👋🏽", "1f44b-1f3fd"},
+	}
+	s := &emojiSanitizer{}
+	for _, test := range tests {
+		if actual := s.getImageCode(test.sequence); actual != test.expected {
+			t.Errorf("getImageCode(%q) = %q, expected %q", test.sequence, actual, test.expected)
+		}
+	}
+}
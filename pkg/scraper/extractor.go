@@ -1,83 +1,126 @@
 package scraper
 
 import (
+	"context"
+	"errors"
 	"net/url"
 
 	"github.com/DaRealFreak/epub-scraper/pkg/config"
-	"github.com/DaRealFreak/epub-scraper/pkg/raven"
+	"github.com/DaRealFreak/epub-scraper/pkg/session"
 	"github.com/PuerkitoBio/goquery"
 	log "github.com/sirupsen/logrus"
 )
 
 // tocContent contains all relevant information for extracting chapter data
 type tocContent struct {
-	toc      *config.Toc
-	cfg      *config.NovelConfig
-	chapters []*ChapterData
+	toc         *config.Toc
+	cfg         *config.NovelConfig
+	chapterURLs []string
 }
 
 // handleToc handles passed Table of Content configurations to extract the Chapter data
-func (s *Scraper) handleToc(toc *config.Toc, cfg *config.NovelConfig) (chapters []*ChapterData) {
+// the chapters are extracted concurrently after collecting the chapter URLs from all pages of the table of contents
+func (s *Scraper) handleToc(
+	ctx context.Context, toc *config.Toc, cfg *config.NovelConfig,
+) (chapters []*ChapterData, err error) {
 	content := &tocContent{
-		toc:      toc,
-		cfg:      cfg,
-		chapters: []*ChapterData{},
+		toc:         toc,
+		cfg:         cfg,
+		chapterURLs: []string{},
+	}
+	if err = s.navigateThroughToc(ctx, toc.URL, content); err != nil {
+		return nil, err
 	}
-	s.navigateThroughToc(toc.URL, content)
 
-	if *toc.Pagination.ReversePosts {
-		for i, j := 0, len(content.chapters)-1; i < j; i, j = i+1, j-1 {
-			content.chapters[i], content.chapters[j] = content.chapters[j], content.chapters[i]
+	if toc.Pagination.ReversePosts != nil && *toc.Pagination.ReversePosts {
+		for i, j := 0, len(content.chapterURLs)-1; i < j; i, j = i+1, j-1 {
+			content.chapterURLs[i], content.chapterURLs[j] = content.chapterURLs[j], content.chapterURLs[i]
 		}
 	}
-	return content.chapters
+
+	chapterStates, err := s.extractChapterStates(ctx, content.chapterURLs, cfg, toc.SourceContent)
+	if err != nil {
+		return nil, err
+	}
+	for _, chapter := range chapterStates {
+		s.state.add(chapter)
+		if chapterData := chapter.chapterData(); chapterData != nil {
+			chapters = append(chapters, chapterData)
+		}
+	}
+	return chapters, nil
 }
 
 // navigateThroughToc navigates through the table of content and extracts chapter links
-func (s *Scraper) navigateThroughToc(tocURL string, content *tocContent) {
+// stops navigating through further pages after the context got canceled
+func (s *Scraper) navigateThroughToc(ctx context.Context, tocURL string, content *tocContent) error {
 	base, err := url.Parse(tocURL)
-	raven.CheckError(err)
-	res, err := s.session.Get(base.String())
-	raven.CheckError(err)
-	doc := s.session.GetDocument(res)
+	if err != nil {
+		return err
+	}
+	res, err := s.session.Get(ctx, base.String(), session.TableOfContents())
+	if s.isCanceled(ctx, err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	doc, err := s.session.GetDocument(res)
+	if err != nil {
+		return err
+	}
+	s.observer.TocPageFetched(tocURL)
 	// extract and append chapter URLs from the current page
 	log.Infof("extracting chapters from %s", tocURL)
-	s.extractChapters(base, doc, content)
+	if err = s.extractChapters(base, doc, content); err != nil {
+		return err
+	}
 
 	// if we have a pagination check for next page and repeat the process
-	if content.toc.Pagination.NextPageSelector != nil {
-		doc.Find(*content.toc.Pagination.NextPageSelector).Each(func(i int, selection *goquery.Selection) {
+	if content.toc.Pagination.NextPageSelector != nil && *content.toc.Pagination.NextPageSelector != "" {
+		doc.Find(*content.toc.Pagination.NextPageSelector).EachWithBreak(func(i int, selection *goquery.Selection) bool {
 			tocPage, exists := selection.Attr("href")
 			if !exists {
-				log.Fatal("chapter selectors have to select a link!")
+				err = &config.SelectorError{
+					Selector: *content.toc.Pagination.NextPageSelector,
+					URL:      tocURL,
+					Err:      errors.New("next page selectors have to select a link"),
+				}
+				return false
 			}
 			// resolve reference to parse relative strings
-			u, err := url.Parse(tocPage)
-			raven.CheckError(err)
+			var u *url.URL
+			if u, err = url.Parse(tocPage); err != nil {
+				return false
+			}
 			tocPage = base.ResolveReference(u).String()
 
 			// prevent infinite loop to same page
 			if tocURL != tocPage {
-				s.navigateThroughToc(tocPage, content)
+				err = s.navigateThroughToc(ctx, tocPage, content)
 			}
+			return err == nil
 		})
 	}
+	return err
 }
 
-// extractChapters extracts possible chapters from the ToC page and resolves the redirects
-func (s *Scraper) extractChapters(base *url.URL, doc *goquery.Document, content *tocContent) {
-	doc.Find(content.toc.ChapterSelector).Each(func(i int, selection *goquery.Selection) {
+// extractChapters extracts the chapter URLs from the ToC page
+func (s *Scraper) extractChapters(base *url.URL, doc *goquery.Document, content *tocContent) (err error) {
+	doc.Find(content.toc.ChapterSelector).EachWithBreak(func(i int, selection *goquery.Selection) bool {
 		chapterURL, exists := selection.Attr("href")
 		if !exists {
 			log.Warningf("no chapter URL found in: %s", base.String())
-			return
+			return true
 		}
-		u, err := url.Parse(chapterURL)
-		raven.CheckError(err)
-		chapterURL = base.ResolveReference(u).String()
-		chapterData := s.extractChapterData(chapterURL, content.cfg, content.toc.SourceContent)
-		if chapterData != nil {
-			content.chapters = append(content.chapters, chapterData)
+		var u *url.URL
+		if u, err = url.Parse(chapterURL); err != nil {
+			return false
 		}
+		chapterURL = base.ResolveReference(u).String()
+		content.chapterURLs = append(content.chapterURLs, chapterURL)
+		s.observer.ChapterLinkDiscovered(chapterURL)
+		return true
 	})
+	return err
 }
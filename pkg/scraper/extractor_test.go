@@ -0,0 +1,54 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+func TestHandleFileError(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "missing.yaml")
+	err := (&Scraper{configParser: config.NewParser()}).HandleFile(context.Background(), fileName)
+	if !errors.Is(err, os.ErrNotExist) || !strings.Contains(err.Error(), "unable to handle "+fileName) {
+		t.Fatalf("expected not existing configuration file error, got %v", err)
+	}
+}
+
+func TestHandleTocSelectorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<a class="chapter" href="/chapter-1">Chapter 1</a><span class="next">Next Page</span>`)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := readTestConfiguration(t, fmt.Sprintf(`cache:
+  disabled: true
+sites:
+  - host: %s
+    rate-limit: 1ms
+chapters:
+  - toc:
+      url: %s/toc
+      chapter-selector: a.chapter
+      pagination:
+        next-page-selector: span.next
+`, serverURL.Host, server.URL))
+
+	_, err = newTestScraper(t, cfg).handleToc(context.Background(), cfg.Chapters[0].Toc, cfg)
+	var selectorError *config.SelectorError
+	if !errors.As(err, &selectorError) || selectorError.Selector != "span.next" || selectorError.URL != server.URL+"/toc" {
+		t.Errorf("expected selector error of the next page selector, got %v", err)
+	}
+}
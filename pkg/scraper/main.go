@@ -2,13 +2,14 @@ package scraper
 
 import (
 	"bytes"
-	"github.com/DaRealFreak/emoji-sanitizer/pkg/sanitizer/options"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
 	"strings"
 
-	"github.com/DaRealFreak/emoji-sanitizer/pkg/sanitizer"
 	"github.com/DaRealFreak/epub-scraper/pkg/config"
-	"github.com/DaRealFreak/epub-scraper/pkg/epub"
-	"github.com/DaRealFreak/epub-scraper/pkg/raven"
 	"github.com/DaRealFreak/epub-scraper/pkg/session"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/html"
@@ -17,72 +18,199 @@ import (
 // Scraper is the main functionality struct
 type Scraper struct {
 	configParser *config.Parser
-	sanitizer    *sanitizer.Sanitizer
+	emojis       *emojiSanitizer
 	session      session.Session
+	options      Options
+	observer     Observer
+	// already extracted chapters of the currently handled novel
+	state *novelState
+	// chapters extracted in the current or the resumed run of the currently handled novel
+	checkpoint *checkpoint
+}
+
+// Options contains the options passed to the scraper, overriding the options of the configuration files
+type Options struct {
+	// Netscape/Mozilla cookies.txt file to load into the session
+	CookieFile string
+	// write the updated cookies back to the cookie file after the run
+	SaveCookies bool
+	// only use cached responses without sending any requests
+	Offline bool
+	// revalidate all cached responses and update the cache with the current responses
+	Refresh bool
+	// extract all chapters again instead of only the chapters missing in the state file
+	Full bool
+	// continue the interrupted previous run from the checkpoint
+	Resume bool
+	// remove the checkpoint of the interrupted previous run and start again
+	DiscardCheckpoint bool
+	// write the already extracted chapters as incomplete epub if the run gets interrupted
+	Partial bool
+	// skip polishing the written epub with calibres ebook-polish command
+	SkipPolish bool
+	// output the epub gets written into instead of <title>.epub in the working directory
+	Output io.Writer
+	// session used for all requests instead of a new session from the novel configuration,
+	// injected sessions are not closed after the run
+	Session session.Session
+	// writer the extracted chapters are added to instead of a new epub writer, only usable for a single novel
+	Writer BookWriter
+	// observer notified about the progress of the build
+	Observer Observer
+	// strip, short-name or image, overrides the configured emoji mode
+	EmojiMode string
+	// unicode version of the emoji data, overrides the configured unicode version
+	EmojiVersion string
+	// never download the emoji data, also set by the offline option
+	EmojiOffline bool
+	// emoji codes kept in addition to the configured allowed emoji codes
+	EmojiAllow []string
 }
 
 // ChapterData contains all relevant chapter data for writing them into the epub
 type ChapterData struct {
-	addPrefix bool
-	title     string
-	content   string
+	// URL of the chapter in the table of contents or the chapters section of the configuration
+	SourceURL string
+	// URL the chapter got extracted from after following all redirects and replacements
+	FinalURL  string
+	Title     string
+	Content   string
+	AddPrefix bool
 }
 
 // NewScraper returns a new scraper struct
-func NewScraper() (_ *Scraper, err error) {
+func NewScraper(scraperOptions Options) (*Scraper, error) {
 	scraper := &Scraper{
 		configParser: config.NewParser(),
+		options:      scraperOptions,
+		observer:     scraperOptions.Observer,
 	}
-	scraper.sanitizer, err = sanitizer.NewSanitizer(
-		options.UnicodeVersion(sanitizer.Version131),
-		options.LoadFromOnline(true),
-		options.UseFallbackToOffline(true),
-		// allow common emojis implemented everywhere: "#", "*", "[0-9]", "©", "®", "‼", "™"
-		options.AllowEmojiCodes([]string{"0023", "002A", "0030..0039", "00A9", "00AE", "203C", "2122"}),
-	)
-
-	return scraper, err
+	if scraper.observer == nil {
+		scraper.observer = NopObserver{}
+	}
+	return scraper, nil
 }
 
 // HandleFile handles a single passed configuration file
-func (s *Scraper) HandleFile(fileName string) {
+// after the context got canceled no new requests are sent and the already extracted chapters are kept in the checkpoint
+// returns the first error occurring while generating the epub wrapped with the passed file name
+func (s *Scraper) HandleFile(ctx context.Context, fileName string) error {
+	if err := s.handleFile(ctx, fileName); err != nil {
+		return fmt.Errorf("unable to handle %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// handleFile reads the passed configuration file and generates the epub of the configured novel
+func (s *Scraper) handleFile(ctx context.Context, fileName string) (err error) {
 	cfg, err := s.configParser.ReadConfigurationFile(fileName)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	s.session = session.NewSession(cfg)
-
-	writer := epub.NewWriter(cfg)
-	for _, source := range cfg.Chapters {
-		if source.Toc != nil {
-			chapters := s.handleToc(source.Toc, cfg)
-			for _, chapter := range chapters {
-				writer.AddChapter(chapter.title, chapter.content, chapter.addPrefix)
-			}
-		} else if source.Chapter != nil {
-			chapter := s.extractChapterData(
-				source.Chapter.URL,
-				cfg,
-				source.Chapter.SourceContent,
-			)
-			if chapter != nil {
-				writer.AddChapter(chapter.title, chapter.content, chapter.addPrefix)
-			}
+		return err
+	}
+
+	// site libraries only used for includes don't contain any chapters
+	if len(cfg.Chapters) == 0 {
+		log.Warningf("no chapters configured in %s, skipping", fileName)
+		return nil
+	}
+
+	if s.state, err = s.loadState(fileName); err != nil {
+		return err
+	}
+	if s.checkpoint, err = s.loadCheckpoint(fileName); err != nil {
+		return err
+	}
+
+	_, err = s.build(ctx, cfg)
+	// interrupted runs are no error for the CLI, the checkpoint is kept to resume the run later on
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return nil
+	}
+	return err
+}
+
+// applyOptions overrides the options of the passed configuration with the options passed to the scraper
+func (s *Scraper) applyOptions(cfg *config.NovelConfig) error {
+	if s.options.CookieFile != "" {
+		cookieFile, err := filepath.Abs(s.options.CookieFile)
+		if err != nil {
+			return err
 		}
+		cfg.Cookies.File = cookieFile
+	}
+	if s.options.SaveCookies {
+		cfg.Cookies.Save = true
+	}
+	if s.options.Offline {
+		cfg.Cache.Offline = true
+	}
+	if s.options.Refresh {
+		cfg.Cache.Refresh = true
+	}
+	if s.options.EmojiMode != "" {
+		cfg.Emojis.Mode = s.options.EmojiMode
+	}
+	if s.options.EmojiVersion != "" {
+		cfg.Emojis.UnicodeVersion = s.options.EmojiVersion
+	}
+	if s.options.EmojiOffline || s.options.Offline {
+		cfg.Emojis.Offline = true
+	}
+	cfg.Emojis.Allow = append(cfg.Emojis.Allow, s.options.EmojiAllow...)
+	return cfg.Emojis.Validate()
+}
+
+// getChapterVolume returns the volume of the passed chapter
+// the volume option of the source has priority over the volume detected from the chapter title,
+// chapters not matching the title regex stay in the volume of the previous chapter
+func (s *Scraper) getChapterVolume(cfg *config.NovelConfig, source config.Source, title string, previousVolume string) string {
+	if source.Volume != "" {
+		return source.Volume
+	}
+	if cfg.Volumes.TitleRegex == "" {
+		return ""
+	}
+	if volume := cfg.Volumes.DetectVolume(title); volume != "" {
+		return volume
+	}
+	return previousVolume
+}
+
+// logReplacementReport logs all URLs which got rewritten by the configured replacements
+func (s *Scraper) logReplacementReport(cfg *config.NovelConfig) {
+	appliedReplacements := cfg.AppliedReplacements()
+	if len(appliedReplacements) == 0 {
+		return
+	}
+	log.Infof("rewrote %d URL(s) through the configured replacements:", len(appliedReplacements))
+	for _, applied := range appliedReplacements {
+		log.Infof("%s -> %s (rule: %s)", applied.OriginalURL, applied.ReplacedURL, applied.MatchedRule)
+	}
+}
+
+// logRevalidationReport logs the amount of not modified and downloaded responses per host
+func (s *Scraper) logRevalidationReport() {
+	report := s.session.RevalidationReport()
+	if len(report) == 0 {
+		return
+	}
+	log.Infof("responses per host (304 not modified / 200 downloaded):")
+	for _, stats := range report {
+		log.Infof("%s: %d / %d", stats.Host, stats.NotModified, stats.Downloaded)
 	}
-	// finally save the generated epub to the file system
-	writer.WriteEpub()
-	writer.PolishEpub()
 }
 
 // fixHTMLCode uses the net/html library to render the broken HTML code which mostly fixes broken HTML
-func (s *Scraper) fixHTMLCode(htmlCode string) string {
+func (s *Scraper) fixHTMLCode(htmlCode string) (string, error) {
 	reader := strings.NewReader(htmlCode)
 	root, err := html.Parse(reader)
-	raven.CheckError(err)
+	if err != nil {
+		return "", err
+	}
 
 	var b bytes.Buffer
-	raven.CheckError(html.Render(&b, root))
-	return b.String()
+	if err = html.Render(&b, root); err != nil {
+		return "", err
+	}
+	return b.String(), nil
 }
@@ -0,0 +1,80 @@
+package scraper
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+	"github.com/DaRealFreak/epub-scraper/pkg/session"
+)
+
+// readTestConfiguration reads the passed content like a novel configuration file
+func readTestConfiguration(t *testing.T, content string) *config.NovelConfig {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), "novel.yaml")
+	if err := ioutil.WriteFile(fileName, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	novelConfig, err := config.NewParser().ReadConfigurationFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return novelConfig
+}
+
+// newTestScraper returns a scraper with an empty state and checkpoint for the passed configuration
+// the emoji names are only loaded from the test data to not download the unicode data
+func newTestScraper(t *testing.T, cfg *config.NovelConfig) *Scraper {
+	t.Helper()
+	cfg.Emojis.Directory = testEmojiDirectory
+	cfg.Emojis.Offline = true
+	s := &Scraper{
+		observer: NopObserver{},
+		state:    &novelState{chapters: make(map[string]*chapterState)},
+	}
+	var err error
+	if s.session, err = session.NewSession(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if s.emojis, err = newEmojiSanitizer(context.Background(), cfg.Emojis, s.session); err != nil {
+		t.Fatal(err)
+	}
+	if s.checkpoint, err = s.loadCheckpoint(filepath.Join(t.TempDir(), "novel.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestGetChapterVolume(t *testing.T) {
+	cfg := readTestConfiguration(t, "volumes:\n  title-regex: ^(?P<Volume>Volume \\d+)\n")
+	tests := []struct {
+		sourceVolume   string
+		title          string
+		previousVolume string
+		expected       string
+	}{
+		{"", "Volume 1 Chapter 1", "", "Volume 1"},
+		{"", "Volume 2 Chapter 1", "Volume 1", "Volume 2"},
+		// chapters not matching the title regex stay in the volume of the previous chapter
+		{"", "Interlude", "Volume 2", "Volume 2"},
+		{"", "Prologue", "", ""},
+		// the volume of the source has priority over the detected volume
+		{"Side Stories", "Volume 3 Chapter 1", "Volume 2", "Side Stories"},
+	}
+
+	s := &Scraper{}
+	for _, test := range tests {
+		actual := s.getChapterVolume(cfg, config.Source{Volume: test.sourceVolume}, test.title, test.previousVolume)
+		if actual != test.expected {
+			t.Errorf("volume of chapter %q is %q, expected %q", test.title, actual, test.expected)
+		}
+	}
+
+	// without title regex only the volumes of the sources are used
+	actual := s.getChapterVolume(&config.NovelConfig{}, config.Source{}, "Volume 1 Chapter 1", "Volume 1")
+	if actual != "" {
+		t.Errorf("expected no volume without title regex, got %q", actual)
+	}
+}
@@ -0,0 +1,42 @@
+package scraper
+
+import "github.com/DaRealFreak/epub-scraper/pkg/epub"
+
+// Observer is notified at the key points of a build f.e. to display the progress
+// chapters are extracted concurrently, so the methods have to be safe for concurrent usage
+type Observer interface {
+	epub.ImageObserver
+	// TocPageFetched is called after a page of the table of contents got retrieved
+	TocPageFetched(tocURL string)
+	// ChapterLinkDiscovered is called for every chapter URL of the table of contents and the chapters section
+	ChapterLinkDiscovered(chapterURL string)
+	// RedirectFollowed is called after following a redirect of a chapter URL
+	RedirectFollowed(fromURL string, toURL string)
+	// ChapterExtracted is called once for every discovered chapter URL after extracting the chapter or taking it
+	// from the state file or the checkpoint, the chapter is nil for skipped chapters
+	ChapterExtracted(chapterURL string, chapter *ChapterData)
+	// EpubWritten is called after the epub got written,
+	// the file name is empty if the epub got written into the output of the options
+	EpubWritten(fileName string)
+}
+
+// NopObserver ignores all events, it can be embedded to only implement the required events of the Observer
+type NopObserver struct{}
+
+// ImageImported ignores the event of an imported image
+func (NopObserver) ImageImported(string, string) {}
+
+// TocPageFetched ignores the event of a retrieved table of contents page
+func (NopObserver) TocPageFetched(string) {}
+
+// ChapterLinkDiscovered ignores the event of a discovered chapter URL
+func (NopObserver) ChapterLinkDiscovered(string) {}
+
+// RedirectFollowed ignores the event of a followed redirect
+func (NopObserver) RedirectFollowed(string, string) {}
+
+// ChapterExtracted ignores the event of an extracted chapter
+func (NopObserver) ChapterExtracted(string, *ChapterData) {}
+
+// EpubWritten ignores the event of a written epub
+func (NopObserver) EpubWritten(string) {}
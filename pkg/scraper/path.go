@@ -1,30 +1,39 @@
 package scraper
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
-
-	log "github.com/sirupsen/logrus"
 )
 
 // HandleDirectory checks and handles all configurations in a passed directory
-func (s *Scraper) HandleDirectory(directoryName string) {
+// the remaining configurations are skipped after the context got canceled or a configuration failed
+func (s *Scraper) HandleDirectory(ctx context.Context, directoryName string) error {
 	files, err := s.filePathWalkDir(directoryName)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	for _, filePath := range files {
+		if ctx.Err() != nil {
+			return nil
+		}
 		if strings.HasSuffix(filePath, ".yaml") {
-			s.HandleFile(filePath)
+			if err = s.HandleFile(ctx, filePath); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
 
 // filePathWalkDir use filepath.Walk to recursively retrieve all files from the passed directory
 func (s *Scraper) filePathWalkDir(root string) ([]string, error) {
 	var files []string
 	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		if !info.IsDir() {
 			files = append(files, path)
 		}
@@ -0,0 +1,154 @@
+package scraper
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// minimum text length of a paragraph to be considered for the content scoring
+const minParagraphLength = 25
+
+var (
+	// elements never being part of the chapter content
+	readabilityIgnoredElements = "script, style, noscript, iframe, form, nav, aside, header, footer, button, input"
+	// class and id names of comment sections and sidebars which are always removed
+	readabilityRemovedPattern = regexp.MustCompile(`(?i)comment|respond|disqus|sidebar|widget|sharedaddy|share-|social|relatedposts|related-posts`)
+	// class and id names of content containers which are never removed even if matching the removed pattern
+	readabilityKeptPattern = regexp.MustCompile(`(?i)entry-content|chapter|reading-content|post-body`)
+	// class and id names lowering or raising the score of a content candidate
+	readabilityNegativePattern = regexp.MustCompile(`(?i)hidden|banner|footer|header|menu|meta|nav|pagination|popup|promo|sponsor|\bads?\b|advert|author|breadcrumb`)
+	readabilityPositivePattern = regexp.MustCompile(`(?i)article|body|chapter|content|entry|main|page|post|text|story`)
+	// block elements, elements without any of these as children are handled as paragraphs
+	readabilityBlockElements = "p, div, article, section, table, ul, ol, pre, blockquote, h1, h2, h3, h4, h5, h6"
+)
+
+// detectContent detects the main chapter content of the passed document without a configured content selector
+// similar to the readability algorithm every paragraph adds to the score of its parent and grandparent element
+// based on its text length and comma count, the element with the highest score weighted by its link density is used
+func (s *Scraper) detectContent(doc *goquery.Document) (string, error) {
+	// work on a copy of the document since we are removing nodes
+	documentHTML, err := doc.Html()
+	if err != nil {
+		return "", err
+	}
+	doc, err = goquery.NewDocumentFromReader(strings.NewReader(documentHTML))
+	if err != nil {
+		return "", err
+	}
+
+	body := doc.Find("body")
+	body.Find(readabilityIgnoredElements).Remove()
+	body.Find("*").Each(func(i int, selection *goquery.Selection) {
+		classAndID := s.getClassAndID(selection)
+		if readabilityRemovedPattern.MatchString(classAndID) && !readabilityKeptPattern.MatchString(classAndID) {
+			selection.Remove()
+		}
+	})
+
+	var candidates []*html.Node
+	scores := make(map[*html.Node]float64)
+	addScore := func(node *html.Node, score float64) {
+		if node == nil || node.Type != html.ElementNode || node.Data == "body" || node.Data == "html" {
+			return
+		}
+		if _, exists := scores[node]; !exists {
+			candidates = append(candidates, node)
+			scores[node] = s.getInitialScore(goquery.NewDocumentFromNode(node).Selection)
+		}
+		scores[node] += score
+	}
+
+	body.Find("p, pre, td, div").Each(func(i int, paragraph *goquery.Selection) {
+		if goquery.NodeName(paragraph) == "div" && paragraph.Find(readabilityBlockElements).Length() > 0 {
+			return
+		}
+		text := strings.TrimSpace(paragraph.Text())
+		if len(text) < minParagraphLength {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + float64(s.min(len(text)/100, 3))
+		parent := paragraph.Nodes[0].Parent
+		addScore(parent, score)
+		if parent != nil {
+			addScore(parent.Parent, score/2)
+		}
+	})
+
+	var bestCandidate *goquery.Selection
+	bestScore := 0.0
+	for _, candidate := range candidates {
+		selection := goquery.NewDocumentFromNode(candidate).Selection
+		score := scores[candidate] * (1 - s.getLinkDensity(selection))
+		if score > bestScore {
+			bestCandidate, bestScore = selection, score
+		}
+	}
+	if bestCandidate == nil {
+		return "", nil
+	}
+
+	s.cleanDetectedContent(bestCandidate)
+	return bestCandidate.Html()
+}
+
+// getInitialScore returns the initial score of a content candidate based on the element and its class and id names
+func (s *Scraper) getInitialScore(selection *goquery.Selection) (score float64) {
+	switch goquery.NodeName(selection) {
+	case "div", "section", "article":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+
+	classAndID := s.getClassAndID(selection)
+	if readabilityNegativePattern.MatchString(classAndID) {
+		score -= 25
+	}
+	if readabilityPositivePattern.MatchString(classAndID) {
+		score += 25
+	}
+	return score
+}
+
+// cleanDetectedContent removes navigation and link lists like previous/next chapter links from the detected content
+func (s *Scraper) cleanDetectedContent(content *goquery.Selection) {
+	content.Find("div, ul, ol, table, section").Each(func(i int, selection *goquery.Selection) {
+		if s.getLinkDensity(selection) > 0.5 || readabilityNegativePattern.MatchString(s.getClassAndID(selection)) &&
+			len(strings.TrimSpace(selection.Text())) < minParagraphLength*4 {
+			selection.Remove()
+		}
+	})
+}
+
+// getLinkDensity returns the ratio of the text inside of links to the whole text of the passed selection
+func (s *Scraper) getLinkDensity(selection *goquery.Selection) float64 {
+	textLength := len(strings.TrimSpace(selection.Text()))
+	if textLength == 0 {
+		return 0
+	}
+	linkLength := 0
+	selection.Find("a").Each(func(i int, link *goquery.Selection) {
+		linkLength += len(strings.TrimSpace(link.Text()))
+	})
+	return float64(linkLength) / float64(textLength)
+}
+
+// getClassAndID returns the class and the id attribute of the passed selection for the pattern matching
+func (s *Scraper) getClassAndID(selection *goquery.Selection) string {
+	return selection.AttrOr("class", "") + " " + selection.AttrOr("id", "")
+}
+
+// min returns the smaller one of the passed integers
+func (s *Scraper) min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
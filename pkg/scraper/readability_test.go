@@ -0,0 +1,89 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// chapter page of a translator blog with navigation, sidebar, chapter links and comments
+const readabilityTestPage = `<html><head><title>Chapter 1</title></head><body>
+<header><nav><a href="/">Home</a><a href="/novels">Novels</a></nav></header>
+<div id="main">
+	<article class="post">
+		<h1 class="entry-title">Chapter 1 - Departure</h1>
+		<div class="entry-content">
+			<p>The carriage left the capital before dawn, rattling over the cobblestones of the empty streets.</p>
+			<p>Nobody came to see her off, which was, all things considered, exactly what she had hoped for.</p>
+			<p>By noon the walls had vanished behind the hills, and with them the last reason to look back.</p>
+			<div class="chapter-nav"><a href="/chapter-0">Previous Chapter</a> | <a href="/chapter-2">Next Chapter</a></div>
+		</div>
+	</article>
+	<div id="comments">
+		<p>Thanks for the chapter, I have been waiting for this translation for a long time now!</p>
+		<p>Finally she leaves the capital, the first arc was dragging on for way too long, in my opinion.</p>
+	</div>
+</div>
+<aside class="sidebar widget-area">
+	<p>Support the translation on Patreon to get early access to the next ten chapters of the novel.</p>
+</aside>
+</body></html>`
+
+func TestDetectContent(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(readabilityTestPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Scraper{}
+	content, err := s.detectContent(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"The carriage left the capital", "exactly what she had hoped for", "last reason"} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("detected content doesn't contain the paragraph %q: %s", expected, content)
+		}
+	}
+	for _, unexpected := range []string{"Next Chapter", "Thanks for the chapter", "Patreon", "Novels"} {
+		if strings.Contains(content, unexpected) {
+			t.Errorf("detected content contains %q: %s", unexpected, content)
+		}
+	}
+	// the passed document is not changed by the detection
+	if doc.Find("#comments").Length() != 1 {
+		t.Errorf("expected unchanged document after detecting the content")
+	}
+
+	doc, err = goquery.NewDocumentFromReader(strings.NewReader(`<html><body><p>Too short.</p></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err = s.detectContent(doc); err != nil || content != "" {
+		t.Errorf("expected no detected content without paragraphs, got %q", content)
+	}
+}
+
+func TestGetLinkDensity(t *testing.T) {
+	tests := []struct {
+		html     string
+		expected float64
+	}{
+		{`<div>plain text</div>`, 0},
+		{`<div><a href="/">link</a></div>`, 1},
+		{`<div>text<a href="/">link</a></div>`, 0.5},
+		{`<div></div>`, 0},
+	}
+
+	s := &Scraper{}
+	for _, test := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(test.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := s.getLinkDensity(doc.Find("div")); actual != test.expected {
+			t.Errorf("link density of %s is %f, expected %f", test.html, actual, test.expected)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// novelState contains all already extracted chapters of a novel to only extract new chapters on the next run
+type novelState struct {
+	Chapters []*chapterState `json:"chapters"`
+	// file the state is loaded from and saved to
+	fileName string
+	// extracted chapters by their URL
+	chapters map[string]*chapterState
+}
+
+// chapterState is a single already extracted chapter
+type chapterState struct {
+	URL         string `json:"url"`
+	FinalURL    string `json:"final-url"`
+	Position    int    `json:"position"`
+	Title       string `json:"title"`
+	ContentHash string `json:"content-hash"`
+	Content     string `json:"content"`
+	AddPrefix   bool   `json:"add-prefix"`
+	// chapters which got skipped f.e. because of blacklisted URLs or titles
+	Skipped bool `json:"skipped"`
+	// hash of the configuration the chapter got extracted with, see getConfigHash
+	ConfigHash string `json:"config-hash"`
+}
+
+// extractionConfig contains all configuration options affecting the extracted chapter data
+// the title and chapter content are separate fields since their embedded cleanup options would collide in JSON
+type extractionConfig struct {
+	TitleContent   config.TitleContent
+	ChapterContent config.ChapterContent
+	// site configurations of the chapter URL and of the final URL, which differ after redirects to other sites
+	Sites        []siteExtractionConfig
+	Replacements []config.Replacement
+	Emojis       config.Emojis
+	// the blacklist is only part of the hash of skipped chapters,
+	// extracted chapters get checked against the current blacklist instead of getting extracted again
+	Blacklist []config.BlacklistEntry `json:",omitempty"`
+}
+
+// siteExtractionConfig contains the options of a site configuration affecting the extracted chapter data
+type siteExtractionConfig struct {
+	Pattern        string
+	Redirects      []string
+	TitleContent   config.TitleContent
+	ChapterContent config.ChapterContent
+}
+
+// getStateFileName returns the file name of the state file of the passed configuration file
+func getStateFileName(fileName string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".state.json"
+}
+
+// newNovelState returns an empty state saved to the passed state file, states without a file are not saved
+func newNovelState(stateFileName string) *novelState {
+	return &novelState{
+		fileName: stateFileName,
+		chapters: make(map[string]*chapterState),
+	}
+}
+
+// loadState loads the state of the passed configuration file, returns an empty state if no state file exists yet
+func (s *Scraper) loadState(fileName string) (*novelState, error) {
+	state := newNovelState(getStateFileName(fileName))
+	if s.options.Full {
+		log.Info("full build, extracting all chapters again")
+		return state, nil
+	}
+
+	content, err := ioutil.ReadFile(filepath.Clean(state.fileName))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, state); err != nil {
+		return nil, err
+	}
+	for _, chapter := range state.Chapters {
+		// chapters with modified content get extracted again
+		if !chapter.Skipped && chapter.ContentHash != getContentHash(chapter.Content) {
+			log.Warningf("content hash of %s doesn't match, extracting it again", chapter.URL)
+			continue
+		}
+		state.chapters[chapter.URL] = chapter
+	}
+	// the list of chapters is built again in the order of the current run
+	state.Chapters = nil
+	log.Infof("loaded %d already extracted chapter(s) from %s", len(state.chapters), state.fileName)
+	return state, nil
+}
+
+// getChapterData returns the chapter data of the passed chapter URL and merges it at the next position into the state
+// returns nil if the chapter got discarded because the context got canceled
+func (s *Scraper) getChapterData(
+	ctx context.Context, chapterURL string, cfg *config.NovelConfig, srcCfg config.SourceContent,
+) (*ChapterData, error) {
+	chapter, err := s.getChapterState(ctx, chapterURL, cfg, srcCfg)
+	if s.isCanceled(ctx, err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.state.add(chapter)
+	return chapter.chapterData(), nil
+}
+
+// getChapterState returns the chapter of the passed chapter URL from the state or the checkpoint of the resumed run
+// and only extracts the chapter if the URL wasn't extracted yet, safe for concurrent usage while no chapter gets added
+func (s *Scraper) getChapterState(
+	ctx context.Context, chapterURL string, cfg *config.NovelConfig, srcCfg config.SourceContent,
+) (*chapterState, error) {
+	chapter, exists := s.state.chapters[chapterURL]
+	if !exists {
+		chapter, exists = s.checkpoint.get(chapterURL)
+	}
+	if exists {
+		chapter, exists = s.reuseChapterState(chapter, cfg, srcCfg)
+	}
+	if exists {
+		log.Debugf("using already extracted chapter from %s", chapterURL)
+		s.observer.ChapterExtracted(chapterURL, chapter.chapterData())
+		return chapter, nil
+	}
+
+	chapterData, err := s.extractChapterData(ctx, chapterURL, cfg, srcCfg)
+	if err != nil {
+		return nil, err
+	}
+	chapter = newChapterState(chapterURL, chapterData)
+	chapter.ConfigHash = chapter.getConfigHash(cfg, srcCfg)
+	// save the chapter directly to not lose it if the run gets interrupted
+	if err = s.checkpoint.add(chapter); err != nil {
+		return nil, err
+	}
+	s.observer.ChapterExtracted(chapterURL, chapter.chapterData())
+	return chapter, nil
+}
+
+// reuseChapterState returns the passed already extracted chapter if it got extracted with the current configuration
+// extracted chapters matching the current blacklist are returned as skipped chapters without extracting them again
+func (s *Scraper) reuseChapterState(
+	chapter *chapterState, cfg *config.NovelConfig, srcCfg config.SourceContent,
+) (*chapterState, bool) {
+	if chapter.ConfigHash != chapter.getConfigHash(cfg, srcCfg) {
+		log.Infof("configuration changed since %s got extracted, extracting it again", chapter.URL)
+		return nil, false
+	}
+	if !chapter.Skipped && (cfg.IsURLBlacklisted(chapter.URL) || cfg.IsURLBlacklisted(chapter.FinalURL) ||
+		cfg.IsTitleBlacklisted(chapter.Title)) {
+		skipped := newChapterState(chapter.URL, nil)
+		skipped.ConfigHash = skipped.getConfigHash(cfg, srcCfg)
+		return skipped, true
+	}
+	return chapter, true
+}
+
+// isCanceled returns true for errors occurring after the context got canceled
+// to discard the unfinished work of interrupted runs instead of returning the error
+func (s *Scraper) isCanceled(ctx context.Context, err error) bool {
+	if err != nil && ctx.Err() != nil {
+		log.Debugf("discarding unfinished work of interrupted run: %s", err.Error())
+		return true
+	}
+	return false
+}
+
+// add merges the passed chapter at the next position into the list of chapters
+func (n *novelState) add(chapter *chapterState) {
+	n.chapters[chapter.URL] = chapter
+	chapter.Position = len(n.Chapters)
+	n.Chapters = append(n.Chapters, chapter)
+}
+
+// save writes the state to the state file
+func (n *novelState) save() error {
+	if n.fileName == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(n, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(n.fileName, content, 0600); err != nil {
+		return err
+	}
+	log.Infof("saved %d chapter(s) to %s", len(n.Chapters), n.fileName)
+	return nil
+}
+
+// newChapterState returns the state of the passed extracted chapter, chapters without data are saved as skipped
+func newChapterState(chapterURL string, chapterData *ChapterData) *chapterState {
+	if chapterData == nil {
+		return &chapterState{URL: chapterURL, Skipped: true}
+	}
+	return &chapterState{
+		URL:         chapterURL,
+		FinalURL:    chapterData.FinalURL,
+		Title:       chapterData.Title,
+		ContentHash: getContentHash(chapterData.Content),
+		Content:     chapterData.Content,
+		AddPrefix:   chapterData.AddPrefix,
+	}
+}
+
+// chapterData returns the chapter data of the saved chapter, returns nil for skipped chapters
+func (c *chapterState) chapterData() *ChapterData {
+	if c.Skipped {
+		return nil
+	}
+	return &ChapterData{
+		SourceURL: c.URL,
+		FinalURL:  c.FinalURL,
+		Title:     c.Title,
+		Content:   c.Content,
+		AddPrefix: c.AddPrefix,
+	}
+}
+
+// getContentHash returns the SHA-256 hash of the passed chapter content
+func getContentHash(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+// getConfigHash returns the SHA-256 hash of all configuration options affecting the extracted chapter data
+// the blacklist is only included for skipped chapters since we can't tell which entry caused the chapter to be skipped
+func (c *chapterState) getConfigHash(cfg *config.NovelConfig, srcCfg config.SourceContent) string {
+	extraction := extractionConfig{
+		TitleContent:   srcCfg.TitleContent,
+		ChapterContent: srcCfg.ChapterContent,
+		Replacements:   cfg.Replacements,
+		Emojis:         cfg.Emojis,
+	}
+	// skipped chapters have no final URL, so only the site configuration of the chapter URL is known
+	for _, siteURL := range []string{c.URL, c.FinalURL} {
+		parsedURL, err := url.Parse(siteURL)
+		if siteURL == "" || err != nil {
+			continue
+		}
+		site := cfg.GetSiteConfigFromURL(parsedURL)
+		extraction.Sites = append(extraction.Sites, siteExtractionConfig{
+			Pattern:        site.Pattern(),
+			Redirects:      site.Redirects,
+			TitleContent:   site.TitleContent,
+			ChapterContent: site.ChapterContent,
+		})
+	}
+	// where the emoji data is loaded from doesn't affect the extracted chapters
+	extraction.Emojis.Offline = false
+	extraction.Emojis.Directory = ""
+	if c.Skipped {
+		extraction.Blacklist = cfg.BackList
+	}
+	// the configuration only consists of strings, booleans and slices of them, so marshalling can't fail
+	content, _ := json.Marshal(extraction)
+	return getContentHash(string(content))
+}
@@ -0,0 +1,166 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+// newTestChapterState returns the state of the passed chapter extracted with an empty configuration
+func newTestChapterState(chapterURL string, chapterData *ChapterData) *chapterState {
+	chapter := newChapterState(chapterURL, chapterData)
+	chapter.ConfigHash = chapter.getConfigHash(&config.NovelConfig{}, config.SourceContent{})
+	return chapter
+}
+
+func TestStateReuse(t *testing.T) {
+	ctx := context.Background()
+	fileName := filepath.Join(t.TempDir(), "novel.yaml")
+	if getStateFileName(fileName) != filepath.Join(filepath.Dir(fileName), "novel.state.json") {
+		t.Fatalf("unexpected state file name %s", getStateFileName(fileName))
+	}
+
+	s := &Scraper{observer: NopObserver{}}
+	state, err := s.loadState(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chapter := range []*chapterState{
+		newTestChapterState("https://www.example.com/chapter-1", &ChapterData{Title: "Chapter 1", Content: "<p>1</p>"}),
+		newTestChapterState("https://www.example.com/teaser", nil),
+		newTestChapterState("https://www.example.com/chapter-2", &ChapterData{Title: "Chapter 2", Content: "<p>2</p>"}),
+	} {
+		state.chapters[chapter.URL] = chapter
+		state.Chapters = append(state.Chapters, chapter)
+	}
+	// modified content has to be extracted again on the next run
+	state.Chapters[2].Content = "<p>modified</p>"
+	if err = state.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.state, err = s.loadState(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.state.chapters) != 2 || len(s.state.Chapters) != 0 {
+		t.Fatalf("expected 2 reusable chapters, got %d", len(s.state.chapters))
+	}
+	// the scraper has no session, so the chapters have to be returned from the state
+	cfg := &config.NovelConfig{}
+	chapter, err := s.getChapterData(ctx, "https://www.example.com/chapter-1", cfg, config.SourceContent{})
+	if err != nil || chapter == nil || chapter.Title != "Chapter 1" || chapter.Content != "<p>1</p>" {
+		t.Errorf("expected chapter 1 from the state, got %+v", chapter)
+	}
+	chapter, err = s.getChapterData(ctx, "https://www.example.com/teaser", cfg, config.SourceContent{})
+	if err != nil || chapter != nil {
+		t.Errorf("expected skipped chapter from the state, got %+v", chapter)
+	}
+	if _, exists := s.state.chapters["https://www.example.com/chapter-2"]; exists {
+		t.Errorf("expected chapter with modified content to be extracted again")
+	}
+	if len(s.state.Chapters) != 2 || s.state.Chapters[1].Position != 1 {
+		t.Errorf("expected the used chapters in the order of the current run, got %+v", s.state.Chapters)
+	}
+
+	// full builds ignore the state file
+	s.options.Full = true
+	if state, err = s.loadState(fileName); err != nil || len(state.chapters) != 0 {
+		t.Errorf("expected empty state for full builds, got %d chapter(s) and error %v", len(state.chapters), err)
+	}
+
+	if err = ioutil.WriteFile(getStateFileName(fileName), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s.options.Full = false
+	if _, err = s.loadState(fileName); err == nil {
+		t.Errorf("expected error for invalid state file")
+	}
+}
+
+func TestReuseChapterState(t *testing.T) {
+	s := &Scraper{}
+	cfg := &config.NovelConfig{}
+	chapter := newTestChapterState("https://www.example.com/chapter-1", &ChapterData{Title: "Chapter 1"})
+	skipped := newTestChapterState("https://www.example.com/teaser", nil)
+	if reused, ok := s.reuseChapterState(chapter, cfg, config.SourceContent{}); !ok || reused != chapter {
+		t.Errorf("expected chapter extracted with the current configuration to be reused")
+	}
+
+	// changed options affecting the chapter data invalidate the extracted chapters
+	changedConfig := readTestConfiguration(t, `
+replacements:
+  - url: https://www.example.com/chapter-1
+    to: https://www.example.com/chapter-1-fixed
+`)
+	if _, ok := s.reuseChapterState(chapter, changedConfig, config.SourceContent{}); ok {
+		t.Errorf("expected chapter to be extracted again after changed replacements")
+	}
+	stripRegex := config.SourceContent{TitleContent: config.TitleContent{
+		CleanupOptions: config.CleanupOptions{StripRegex: "^(?P<Title>.+)$"},
+	}}
+	if _, ok := s.reuseChapterState(chapter, cfg, stripRegex); ok {
+		t.Errorf("expected chapter to be extracted again after changed title options")
+	}
+	// the location of the emoji data doesn't affect the chapter data
+	if _, ok := s.reuseChapterState(chapter, &config.NovelConfig{
+		Emojis: config.Emojis{Offline: true, Directory: t.TempDir()},
+	}, config.SourceContent{}); !ok {
+		t.Errorf("expected chapter to be reused with different emoji data directory")
+	}
+
+	// newly blacklisted chapters are skipped without extracting them again,
+	// while skipped chapters are extracted again after blacklist changes
+	blacklistConfig := readTestConfiguration(t, "blacklist:\n  - title: Chapter 1\n")
+	reused, ok := s.reuseChapterState(chapter, blacklistConfig, config.SourceContent{})
+	if !ok || !reused.Skipped || reused.URL != chapter.URL {
+		t.Errorf("expected blacklisted chapter to be skipped, got %+v", reused)
+	}
+	if _, ok = s.reuseChapterState(skipped, blacklistConfig, config.SourceContent{}); ok {
+		t.Errorf("expected skipped chapter to be extracted again after blacklist changes")
+	}
+}
+
+func TestReuseChapterStateSiteChanges(t *testing.T) {
+	readSiteConfiguration := func(redirect string, orgSelector string, netSelector string) *config.NovelConfig {
+		return readTestConfiguration(t, fmt.Sprintf(`sites:
+  - host: www.example.com
+    redirects:
+      - %s
+  - host: www.example.org
+    chapter-content:
+      content-selector: %s
+  - host: www.example.net
+    chapter-content:
+      content-selector: %s
+`, redirect, orgSelector, netSelector))
+	}
+	s := &Scraper{}
+	cfg := readSiteConfiguration("a.next", "div.content", "div.content")
+	// the chapter got redirected to the site configuration of www.example.org
+	chapter := newChapterState("https://www.example.com/chapter-1", &ChapterData{
+		Title:    "Chapter 1",
+		FinalURL: "https://www.example.org/chapter-1",
+	})
+	chapter.ConfigHash = chapter.getConfigHash(cfg, config.SourceContent{})
+
+	tests := []struct {
+		name     string
+		cfg      *config.NovelConfig
+		expected bool
+	}{
+		{"unchanged sites", readSiteConfiguration("a.next", "div.content", "div.content"), true},
+		{"changed site of the chapter URL", readSiteConfiguration("a.continue", "div.content", "div.content"), false},
+		{"changed site of the final URL", readSiteConfiguration("a.next", "div.other", "div.content"), false},
+		{"changed unrelated site", readSiteConfiguration("a.next", "div.content", "div.other"), true},
+	}
+	for _, test := range tests {
+		if _, ok := s.reuseChapterState(chapter, test.cfg, config.SourceContent{}); ok != test.expected {
+			t.Errorf("%s: expected reused chapter: %t, got %t", test.name, test.expected, ok)
+		}
+	}
+}
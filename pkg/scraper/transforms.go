@@ -0,0 +1,65 @@
+package scraper
+
+import (
+	"strings"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/atom"
+)
+
+// applyTransforms applies the configured transformation steps in the declared order to the passed html
+func (s *Scraper) applyTransforms(htmlContent string, transforms []config.Transform) (string, error) {
+	for _, transform := range transforms {
+		// regular expressions are working on the raw html, every other step on the parsed document
+		if transform.Replace != nil {
+			htmlContent = transform.Replace.Pattern().ReplaceAllString(htmlContent, transform.Replace.With)
+			continue
+		}
+
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+		if err != nil {
+			return "", err
+		}
+		body := doc.Find("body")
+
+		switch {
+		case transform.Remove != "":
+			body.Find(transform.Remove).Remove()
+		case transform.Unwrap != "":
+			body.Find(transform.Unwrap).Each(func(i int, selection *goquery.Selection) {
+				selection.ReplaceWithSelection(selection.Contents())
+			})
+		case transform.Keep != "":
+			keptContent := ""
+			body.Find(transform.Keep).EachWithBreak(func(i int, selection *goquery.Selection) bool {
+				var outerHTML string
+				outerHTML, err = goquery.OuterHtml(selection)
+				keptContent += outerHTML
+				return err == nil
+			})
+			if err != nil {
+				return "", err
+			}
+			htmlContent = keptContent
+			continue
+		case transform.Rename != nil:
+			body.Find(transform.Rename.Selector).Each(func(i int, selection *goquery.Selection) {
+				for _, node := range selection.Nodes {
+					node.Data = transform.Rename.Tag
+					node.DataAtom = atom.Lookup([]byte(transform.Rename.Tag))
+				}
+			})
+		case transform.SetAttribute != nil:
+			body.Find(transform.SetAttribute.Selector).SetAttr(transform.SetAttribute.Name, transform.SetAttribute.Value)
+		case transform.RemoveAttribute != nil:
+			body.Find(transform.RemoveAttribute.Selector).RemoveAttr(transform.RemoveAttribute.Name)
+		}
+
+		if htmlContent, err = body.Html(); err != nil {
+			return "", err
+		}
+	}
+
+	return htmlContent, nil
+}
@@ -0,0 +1,89 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+// readTransforms reads the passed YAML transforms like the chapter content transforms of a configuration file
+func readTransforms(t *testing.T, transforms string) []config.Transform {
+	t.Helper()
+	novelConfig := readTestConfiguration(t, "chapters:\n  - chapter:\n      url: https://www.example.com/chapter-1\n"+
+		"      chapter-content:\n        transforms:\n"+
+		"          "+strings.ReplaceAll(strings.TrimSpace(transforms), "\n", "\n          ")+"\n")
+	return *novelConfig.Chapters[0].Chapter.ChapterContent.Transforms
+}
+
+func TestApplyTransforms(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		transforms string
+		expected   string
+	}{
+		{
+			name:       "remove",
+			content:    `<p>text</p><div class="ad">advertisement</div>`,
+			transforms: `- remove: .ad`,
+			expected:   `<p>text</p>`,
+		},
+		{
+			name:       "unwrap",
+			content:    `<p><span class="font">some <b>bold</b> text</span></p>`,
+			transforms: `- unwrap: span.font`,
+			expected:   `<p>some <b>bold</b> text</p>`,
+		},
+		{
+			name:       "keep",
+			content:    `<div><p class="text">first</p><p>note</p><p class="text">second</p></div>`,
+			transforms: `- keep: p.text`,
+			expected:   `<p class="text">first</p><p class="text">second</p>`,
+		},
+		{
+			name:       "rename",
+			content:    `<p><b>bold</b></p>`,
+			transforms: `- rename: {selector: b, tag: strong}`,
+			expected:   `<p><strong>bold</strong></p>`,
+		},
+		{
+			name:       "replace with capture groups",
+			content:    `<p>Chapter 12</p>`,
+			transforms: `- replace: {regex: 'Chapter (?P<number>\d+)', with: 'Chapter ${number} (translated)'}`,
+			expected:   `<p>Chapter 12 (translated)</p>`,
+		},
+		{
+			name:       "set attribute",
+			content:    `<img src="image.png"/>`,
+			transforms: `- set-attribute: {selector: img, name: alt, value: illustration}`,
+			expected:   `<img src="image.png" alt="illustration"/>`,
+		},
+		{
+			name:       "remove attribute",
+			content:    `<p style="color: red" class="text">text</p>`,
+			transforms: `- remove-attribute: {selector: p, name: style}`,
+			expected:   `<p class="text">text</p>`,
+		},
+		{
+			name:    "declared order",
+			content: `<p>[TL note: hello]</p><p>text</p>`,
+			transforms: `
+- replace: {regex: '<p>\[TL note: [^\]]*\]</p>', with: '<p class="note"></p>'}
+- remove: p.note`,
+			expected: `<p>text</p>`,
+		},
+	}
+
+	s := &Scraper{}
+	for _, test := range tests {
+		actual, err := s.applyTransforms(test.content, readTransforms(t, test.transforms))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if actual != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, actual)
+		}
+	}
+}
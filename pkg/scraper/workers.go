@@ -0,0 +1,81 @@
+package scraper
+
+import (
+	"context"
+	"sync"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// default amount of chapters extracted at the same time if no concurrency is configured
+const defaultConcurrency = 4
+
+// extractChapterStates extracts the passed chapter URLs concurrently and returns the chapters in the order of the URLs
+// the amount of requests sent to a single host at the same time is limited by the session
+// after the context got canceled no new chapters are extracted and the chapters are cut off at the first discarded chapter
+// the first error of a chapter stops the extraction of the remaining chapters and is returned
+func (s *Scraper) extractChapterStates(
+	ctx context.Context, chapterURLs []string, cfg *config.NovelConfig, srcCfg config.SourceContent,
+) ([]*chapterState, error) {
+	workers := defaultConcurrency
+	if cfg.Concurrency > 0 {
+		workers = cfg.Concurrency
+	}
+	if workers > len(chapterURLs) {
+		workers = len(chapterURLs)
+	}
+	log.Debugf("extracting %d chapter(s) with %d worker(s)", len(chapterURLs), workers)
+
+	// the work of the remaining workers is discarded after the first error
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var firstErr error
+	errOnce := sync.Once{}
+
+	// every worker writes only the index of its current job, so the results keep the order of the URLs
+	chapters := make([]*chapterState, len(chapterURLs))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				chapter, err := s.getChapterState(workerCtx, chapterURLs[index], cfg, srcCfg)
+				switch {
+				case s.isCanceled(workerCtx, err):
+				case err != nil:
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				default:
+					chapters[index] = chapter
+				}
+			}
+		}()
+	}
+dispatch:
+	for index := range chapterURLs {
+		// select chooses randomly between ready cases, so the context is checked before every job
+		if workerCtx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- index:
+		case <-workerCtx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// chapters after the first discarded chapter are dropped too, so the chapters never contain gaps
+	for index, chapter := range chapters {
+		if chapter == nil {
+			return chapters[:index], firstErr
+		}
+	}
+	return chapters, firstErr
+}
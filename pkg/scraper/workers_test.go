@@ -0,0 +1,162 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+func TestExtractChaptersConcurrently(t *testing.T) {
+	const chapterCount = 8
+	var (
+		mutex               sync.Mutex
+		running, maxRunning int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/toc" {
+			for i := 1; i <= chapterCount; i++ {
+				_, _ = fmt.Fprintf(w, `<a class="chapter" href="/chapter-%d">Chapter %d</a>`, i, i)
+			}
+			return
+		}
+
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		// the first chapters take the longest to finish them in the reversed order
+		var chapter int
+		_, _ = fmt.Sscanf(r.URL.Path, "/chapter-%d", &chapter)
+		time.Sleep(time.Duration(chapterCount-chapter) * 5 * time.Millisecond)
+		_, _ = fmt.Fprintf(w, `<h1>Chapter %d</h1><div class="content"><p>content %d</p></div>`, chapter, chapter)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := readTestConfiguration(t, fmt.Sprintf(`concurrency: 4
+cache:
+  disabled: true
+sites:
+  - host: %s
+    rate-limit: 1ms
+    burst: 10
+    concurrency: 3
+chapters:
+  - toc:
+      url: %s/toc
+      chapter-selector: a.chapter
+      title-content:
+        title-selector: h1
+      chapter-content:
+        content-selector: div.content
+`, serverURL.Host, server.URL))
+
+	s := newTestScraper(t, cfg)
+	chapters, err := s.handleToc(context.Background(), cfg.Chapters[0].Toc, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chapters) != chapterCount {
+		t.Fatalf("expected %d chapters, got %d", chapterCount, len(chapters))
+	}
+	for i, chapter := range chapters {
+		expectedTitle := fmt.Sprintf("Chapter %d", i+1)
+		if chapter.Title != expectedTitle || !strings.Contains(chapter.Content, fmt.Sprintf("content %d", i+1)) {
+			t.Errorf("expected %s at position %d, got %s", expectedTitle, i, chapter.Title)
+		}
+		if state := s.state.Chapters[i]; state.Title != expectedTitle || state.Position != i {
+			t.Errorf("expected %s at position %d in the state, got %s at %d", expectedTitle, i, state.Title, state.Position)
+		}
+	}
+	if maxRunning < 2 || maxRunning > 3 {
+		t.Errorf("expected concurrent requests limited to the site concurrency of 3, got %d", maxRunning)
+	}
+}
+
+// extractedObserver calls the passed function for every extracted chapter
+type extractedObserver struct {
+	NopObserver
+	extracted func(chapterURL string)
+}
+
+func (o extractedObserver) ChapterExtracted(chapterURL string, _ *ChapterData) {
+	o.extracted(chapterURL)
+}
+
+func TestExtractChaptersCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the second chapter only finishes after the run got interrupted
+		if r.URL.Path == "/chapter-2" {
+			<-r.Context().Done()
+			return
+		}
+		_, _ = fmt.Fprintf(w, `<h1>%s</h1><div class="content"><p>content</p></div>`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := readTestConfiguration(t, fmt.Sprintf(`concurrency: 3
+cache:
+  disabled: true
+sites:
+  - host: %s
+    rate-limit: 1ms
+    burst: 10
+    concurrency: 3
+    title-content:
+      title-selector: h1
+    chapter-content:
+      content-selector: div.content
+`, serverURL.Host))
+
+	var chapterURLs []string
+	for i := 1; i <= 6; i++ {
+		chapterURLs = append(chapterURLs, fmt.Sprintf("%s/chapter-%d", server.URL, i))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		mutex     sync.Mutex
+		extracted = make(map[string]bool)
+	)
+	s := newTestScraper(t, cfg)
+	// interrupt the run after the chapters around the unfinished second chapter got extracted
+	s.observer = extractedObserver{extracted: func(chapterURL string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		extracted[chapterURL] = true
+		if extracted[chapterURLs[0]] && extracted[chapterURLs[2]] {
+			cancel()
+		}
+	}}
+
+	chapters, err := s.extractChapterStates(ctx, chapterURLs, cfg, config.SourceContent{})
+	if err != nil {
+		t.Fatalf("expected discarded work without error, got %v", err)
+	}
+	// the already extracted third chapter is dropped to not leave a gap for the discarded second chapter
+	if len(chapters) != 1 || chapters[0].URL != chapterURLs[0] {
+		t.Errorf("expected only the first chapter before the discarded chapter, got %d chapter(s)", len(chapters))
+	}
+}
@@ -0,0 +1,193 @@
+package session
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// name of the cache directory inside of the user cache directory
+const cacheDirectoryName = "epub-scraper"
+
+// RequestOption is an option changing the handling of a single request
+type RequestOption func(options *requestOptions)
+
+// requestOptions contains all options of a single request
+type requestOptions struct {
+	tableOfContents bool
+}
+
+// TableOfContents marks the request as table of content request, which uses the table of content cache TTL
+func TableOfContents() RequestOption {
+	return func(options *requestOptions) {
+		options.tableOfContents = true
+	}
+}
+
+// newRequestOptions applies the passed request options
+func newRequestOptions(opts []RequestOption) *requestOptions {
+	options := &requestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// responseCache is a persistent cache of successful GET responses keyed by the requested URL
+type responseCache struct {
+	directory string
+}
+
+// cacheEntry contains the meta data of a cached response, the body is stored in a separate file
+type cacheEntry struct {
+	URL        string      `json:"url"`
+	FinalURL   string      `json:"final-url"`
+	StatusCode int         `json:"status-code"`
+	Header     http.Header `json:"header"`
+	Created    time.Time   `json:"created"`
+	// body of the response, stored in a separate file
+	body []byte
+}
+
+// newResponseCache returns a response cache using the passed directory
+// the epub-scraper directory in the user cache directory is used if no directory is passed
+func newResponseCache(directory string) (*responseCache, error) {
+	if directory == "" {
+		userCacheDirectory, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		directory = filepath.Join(userCacheDirectory, cacheDirectoryName)
+	}
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, err
+	}
+	return &responseCache{directory: directory}, nil
+}
+
+// getCachedResponse returns the cached response of the passed URI if we are offline or the cached response didn't expire
+// expired or refreshed entries are returned as stale entry to revalidate them with a conditional request
+func (s *session) getCachedResponse(uri string, options *requestOptions) (*http.Response, *cacheEntry, error) {
+	entry, err := s.cache.load(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry == nil {
+		if s.cfg.Cache.Offline {
+			return nil, nil, fmt.Errorf("%s is not cached, unable to retrieve it in offline mode", uri)
+		}
+		return nil, nil, nil
+	}
+
+	if !s.cfg.Cache.Offline {
+		if s.cfg.Cache.Refresh {
+			return nil, entry, nil
+		}
+		if ttl := s.getCacheTTL(uri, options); ttl > 0 && time.Since(entry.Created) > ttl {
+			log.Debugf("cached response of %s expired", uri)
+			return nil, entry, nil
+		}
+	}
+
+	response, err := entry.response()
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Debugf("using cached response of %s", uri)
+	return response, nil, nil
+}
+
+// getCacheTTL returns the time to live of the cached response of the passed URI from the matching site configuration
+// requests to the wayback machine use the time to live of the archived site
+func (s *session) getCacheTTL(uri string, options *requestOptions) time.Duration {
+	parsedURL, err := url.Parse(uri)
+	if err != nil {
+		return 0
+	}
+	if archivedURL, ok := getArchivedURL(parsedURL); ok {
+		parsedURL = archivedURL
+	}
+	siteConfig := s.cfg.GetSiteConfigFromURL(parsedURL)
+	return siteConfig.CacheTTL.GetTTL(options.tableOfContents)
+}
+
+// getPath returns the path of the passed cache file for the passed URI
+func (c *responseCache) getPath(uri string, extension string) string {
+	hash := sha256.Sum256([]byte(uri))
+	return filepath.Join(c.directory, hex.EncodeToString(hash[:])+extension)
+}
+
+// load returns the cached entry of the passed URI, returns nil if the URI is not cached
+func (c *responseCache) load(uri string) (*cacheEntry, error) {
+	meta, err := ioutil.ReadFile(c.getPath(uri, ".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &cacheEntry{}
+	if err = json.Unmarshal(meta, entry); err != nil {
+		return nil, err
+	}
+	entry.body, err = ioutil.ReadFile(c.getPath(uri, ".body"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return entry, err
+}
+
+// store saves the passed response and the already read body of the passed URI in the cache
+func (c *responseCache) store(uri string, response *http.Response, body []byte) error {
+	return c.storeEntry(&cacheEntry{
+		URL:        uri,
+		FinalURL:   response.Request.URL.String(),
+		StatusCode: response.StatusCode,
+		Header:     response.Header,
+		Created:    time.Now(),
+		body:       body,
+	})
+}
+
+// storeEntry saves the passed entry in the cache
+func (c *responseCache) storeEntry(entry *cacheEntry) error {
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// write the body first, entries are only valid with an existing meta data file
+	if err = ioutil.WriteFile(c.getPath(entry.URL, ".body"), entry.body, 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.getPath(entry.URL, ".json"), meta, 0600)
+}
+
+// response returns the cached response with the final URL as request URL for resolving relative links
+func (e *cacheEntry) response() (*http.Response, error) {
+	finalURL, err := url.Parse(e.FinalURL)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       &http.Request{Method: http.MethodGet, URL: finalURL, Header: make(http.Header)},
+	}, nil
+}
@@ -0,0 +1,209 @@
+package session
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+func TestGetCachedResponse(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		name            string
+		cache           config.Cache
+		cacheTTL        config.CacheTTL
+		tableOfContents bool
+		age             time.Duration
+		expectResponse  bool
+		expectStale     bool
+	}{
+		{name: "chapter never expires", age: 365 * day, expectResponse: true},
+		{name: "table of contents default ttl", tableOfContents: true, age: time.Minute, expectResponse: true},
+		{name: "table of contents expired", tableOfContents: true, age: 2 * time.Hour, expectStale: true},
+		{name: "chapter ttl", cacheTTL: config.CacheTTL{Chapter: &day}, age: time.Hour, expectResponse: true},
+		{name: "chapter ttl expired", cacheTTL: config.CacheTTL{Chapter: &day}, age: 2 * day, expectStale: true},
+		{name: "refresh", cache: config.Cache{Refresh: true}, age: time.Minute, expectStale: true},
+		{
+			name: "offline ignores ttl", cache: config.Cache{Offline: true}, tableOfContents: true, age: day,
+			expectResponse: true,
+		},
+	}
+
+	const uri = "https://www.example.com/novel"
+	for _, test := range tests {
+		cache, err := newResponseCache(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = cache.storeEntry(&cacheEntry{
+			URL:        uri,
+			FinalURL:   uri,
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Etag": []string{`"v1"`}},
+			Created:    time.Now().Add(-test.age),
+			body:       []byte("cached"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		s := &session{
+			cfg: &config.NovelConfig{
+				Cache: test.cache,
+				Sites: []config.SiteConfiguration{{Host: "www.example.com", CacheTTL: test.cacheTTL}},
+			},
+			cache: cache,
+		}
+
+		var opts []RequestOption
+		if test.tableOfContents {
+			opts = append(opts, TableOfContents())
+		}
+		response, stale, err := s.getCachedResponse(uri, newRequestOptions(opts))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if (response != nil) != test.expectResponse || (stale != nil) != test.expectStale {
+			t.Errorf(
+				"%s: got response %t and stale entry %t, expected response %t and stale entry %t",
+				test.name, response != nil, stale != nil, test.expectResponse, test.expectStale,
+			)
+		}
+	}
+}
+
+func TestGetCachedResponseMissing(t *testing.T) {
+	cache, err := newResponseCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &session{cfg: &config.NovelConfig{}, cache: cache}
+	response, stale, err := s.getCachedResponse("https://www.example.com/novel", newRequestOptions(nil))
+	if response != nil || stale != nil || err != nil {
+		t.Errorf("expected nothing for missing entries, got response %v, stale entry %v and error %v", response, stale, err)
+	}
+
+	s.cfg.Cache.Offline = true
+	if _, _, err = s.getCachedResponse("https://www.example.com/novel", newRequestOptions(nil)); err == nil {
+		t.Errorf("expected error for missing entries in offline mode")
+	}
+}
+
+func TestGetCached(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte("chapter"))
+	}))
+	defer server.Close()
+
+	directory := t.TempDir()
+	caches := []config.Cache{{Directory: directory}, {Directory: directory}, {Directory: directory, Offline: true}}
+	for _, cache := range caches {
+		response, err := newTestSession(t, server, 0, cache).Get(context.Background(), server.URL+"/chapter-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if err != nil || string(body) != "chapter" {
+			t.Errorf("expected the chapter response, got %q and error %v", string(body), err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected a single request with the response cache, got %d", requests)
+	}
+}
+
+func TestRevalidation(t *testing.T) {
+	var requests, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("table of contents"))
+	}))
+	defer server.Close()
+
+	directory := t.TempDir()
+	s := newTestSession(t, server, 0, config.Cache{Directory: directory})
+	readBody := func() string {
+		response, err := s.Get(context.Background(), server.URL+"/toc", TableOfContents())
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = response.Body.Close()
+		return string(body)
+	}
+
+	if body := readBody(); body != "table of contents" || atomic.LoadInt32(&requests) != 1 {
+		t.Fatalf("expected the downloaded response, got %q after %d request(s)", body, atomic.LoadInt32(&requests))
+	}
+	if body := readBody(); body != "table of contents" || atomic.LoadInt32(&requests) != 1 {
+		t.Fatalf("expected the cached response, got %q after %d request(s)", body, atomic.LoadInt32(&requests))
+	}
+
+	// refreshed sessions revalidate every cached response and reuse the cached body of not modified responses
+	s = newTestSession(t, server, 0, config.Cache{Directory: directory, Refresh: true})
+	if body := readBody(); body != "table of contents" || atomic.LoadInt32(&notModified) != 1 {
+		t.Fatalf("expected the revalidated response, got %q after %d request(s)", body, atomic.LoadInt32(&requests))
+	}
+	report := s.RevalidationReport()
+	if len(report) != 1 || report[0].NotModified != 1 || report[0].Downloaded != 0 {
+		t.Errorf("unexpected revalidation report %+v", report)
+	}
+}
+
+func TestGetConditionalHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		stale    *cacheEntry
+		expected http.Header
+	}{
+		{name: "no stale entry"},
+		{name: "no validators", stale: &cacheEntry{Header: http.Header{"Content-Type": []string{"text/html"}}}},
+		{
+			name:     "etag",
+			stale:    &cacheEntry{Header: http.Header{"Etag": []string{`"v1"`}}},
+			expected: http.Header{"If-None-Match": []string{`"v1"`}},
+		},
+		{
+			name: "etag and last modified",
+			stale: &cacheEntry{Header: http.Header{
+				"Etag":          []string{`"v1"`},
+				"Last-Modified": []string{"Wed, 21 Oct 2015 07:28:00 GMT"},
+			}},
+			expected: http.Header{
+				"If-None-Match":     []string{`"v1"`},
+				"If-Modified-Since": []string{"Wed, 21 Oct 2015 07:28:00 GMT"},
+			},
+		},
+	}
+	s := &session{}
+	for _, test := range tests {
+		actual := s.getConditionalHeader(test.stale)
+		if len(actual) != len(test.expected) {
+			t.Errorf("%s: expected header %v, got %v", test.name, test.expected, actual)
+			continue
+		}
+		for name := range test.expected {
+			if actual.Get(name) != test.expected.Get(name) {
+				t.Errorf("%s: expected header %v, got %v", test.name, test.expected, actual)
+			}
+		}
+	}
+}
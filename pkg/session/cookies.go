@@ -0,0 +1,208 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prefix of HttpOnly cookies in the Netscape cookie file format
+const httpOnlyPrefix = "#HttpOnly_"
+
+// cookieEntry is a single cookie of a Netscape/Mozilla cookies.txt file
+type cookieEntry struct {
+	domain            string
+	includeSubdomains bool
+	path              string
+	secure            bool
+	httpOnly          bool
+	// zero value for session cookies
+	expires time.Time
+	name    string
+	value   string
+}
+
+// recordingJar is a cookie jar which keeps track of all cookies to be able to write them back to a cookies.txt file
+type recordingJar struct {
+	*cookiejar.Jar
+	entries map[string]*cookieEntry
+	mutex   sync.Mutex
+}
+
+// newRecordingJar returns a new cookie jar recording all set cookies
+func newRecordingJar() *recordingJar {
+	jar, _ := cookiejar.New(nil)
+	return &recordingJar{
+		Jar:     jar,
+		entries: make(map[string]*cookieEntry),
+	}
+}
+
+// SetCookies implements the http.CookieJar interface and records the cookies before passing them to the cookie jar
+func (j *recordingJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mutex.Lock()
+	for _, cookie := range cookies {
+		entry := &cookieEntry{
+			domain:   strings.ToLower(u.Hostname()),
+			path:     cookie.Path,
+			secure:   cookie.Secure,
+			httpOnly: cookie.HttpOnly,
+			expires:  cookie.Expires,
+			name:     cookie.Name,
+			value:    cookie.Value,
+		}
+		if cookie.Domain != "" {
+			entry.domain = strings.TrimPrefix(strings.ToLower(cookie.Domain), ".")
+			entry.includeSubdomains = true
+		}
+		if entry.path == "" || !strings.HasPrefix(entry.path, "/") {
+			// default path of cookies is the directory of the request path
+			entry.path = path.Dir(u.EscapedPath())
+			if entry.path == "." {
+				entry.path = "/"
+			}
+		}
+		if cookie.MaxAge > 0 {
+			entry.expires = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+
+		if cookie.MaxAge < 0 || (!entry.expires.IsZero() && entry.expires.Before(time.Now())) {
+			delete(j.entries, entry.key())
+		} else {
+			j.entries[entry.key()] = entry
+		}
+	}
+	j.mutex.Unlock()
+
+	j.Jar.SetCookies(u, cookies)
+}
+
+// LoadFile loads all not expired cookies of the passed cookies.txt file into the cookie jar
+func (j *recordingJar) LoadFile(fileName string) error {
+	content, err := ioutil.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		entry, err := j.parseLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", fileName, lineNumber, err)
+		}
+		if entry == nil || (!entry.expires.IsZero() && entry.expires.Before(time.Now())) {
+			continue
+		}
+
+		scheme := "http"
+		if entry.secure {
+			scheme = "https"
+		}
+		cookie := &http.Cookie{
+			Name:     entry.name,
+			Value:    entry.value,
+			Path:     entry.path,
+			Secure:   entry.secure,
+			HttpOnly: entry.httpOnly,
+			Expires:  entry.expires,
+		}
+		if entry.includeSubdomains {
+			cookie.Domain = entry.domain
+		}
+		j.SetCookies(&url.URL{Scheme: scheme, Host: entry.domain, Path: entry.path}, []*http.Cookie{cookie})
+	}
+	return scanner.Err()
+}
+
+// parseLine parses a single line of a cookies.txt file, returns nil for comments and empty lines
+func (j *recordingJar) parseLine(line string) (*cookieEntry, error) {
+	httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+	if httpOnly {
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+	}
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("expected 7 tab separated fields, got %d", len(fields))
+	}
+	expires, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expiration date %q", fields[4])
+	}
+
+	entry := &cookieEntry{
+		domain:            strings.TrimPrefix(strings.ToLower(fields[0]), "."),
+		includeSubdomains: strings.EqualFold(fields[1], "TRUE"),
+		path:              fields[2],
+		secure:            strings.EqualFold(fields[3], "TRUE"),
+		httpOnly:          httpOnly,
+		name:              fields[5],
+		value:             fields[6],
+	}
+	if expires > 0 {
+		entry.expires = time.Unix(expires, 0)
+	}
+	return entry, nil
+}
+
+// SaveFile writes all recorded cookies into the passed file in the Netscape cookies.txt format
+func (j *recordingJar) SaveFile(fileName string) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	keys := make([]string, 0, len(j.entries))
+	for key := range j.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("# Netscape HTTP Cookie File\n")
+	for _, key := range keys {
+		buffer.WriteString(j.entries[key].String() + "\n")
+	}
+	return ioutil.WriteFile(fileName, buffer.Bytes(), 0600)
+}
+
+// key returns the unique key of the cookie entry, cookies are identified by their domain, path and name
+func (e *cookieEntry) key() string {
+	return e.domain + ";" + e.path + ";" + e.name
+}
+
+// String returns the cookie entry as line of the Netscape cookies.txt format
+func (e *cookieEntry) String() string {
+	domain := e.domain
+	if e.includeSubdomains {
+		domain = "." + domain
+	}
+	if e.httpOnly {
+		domain = httpOnlyPrefix + domain
+	}
+	var expires int64
+	if !e.expires.IsZero() {
+		expires = e.expires.Unix()
+	}
+	return strings.Join([]string{
+		domain,
+		strings.ToUpper(strconv.FormatBool(e.includeSubdomains)),
+		e.path,
+		strings.ToUpper(strconv.FormatBool(e.secure)),
+		strconv.FormatInt(expires, 10),
+		e.name,
+		e.value,
+	}, "\t")
+}
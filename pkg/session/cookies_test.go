@@ -0,0 +1,123 @@
+package session
+
+import (
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		expected    *cookieEntry
+		expectError bool
+	}{
+		{name: "comment", line: "# Netscape HTTP Cookie File"},
+		{name: "empty line", line: "   "},
+		{
+			name: "session cookie",
+			line: "www.example.com\tFALSE\t/\tFALSE\t0\tsession\tabc",
+			expected: &cookieEntry{
+				domain: "www.example.com", path: "/", name: "session", value: "abc",
+			},
+		},
+		{
+			name: "subdomains with expiration",
+			line: ".Example.com\tTRUE\t/novel\tTRUE\t2000000000\ttoken\tx=y",
+			expected: &cookieEntry{
+				domain: "example.com", includeSubdomains: true, path: "/novel", secure: true,
+				expires: time.Unix(2000000000, 0), name: "token", value: "x=y",
+			},
+		},
+		{
+			name: "http only with windows line ending",
+			line: "#HttpOnly_.example.com\tTRUE\t/\tFALSE\t0\tid\t42\r",
+			expected: &cookieEntry{
+				domain: "example.com", includeSubdomains: true, path: "/", httpOnly: true, name: "id", value: "42",
+			},
+		},
+		{name: "missing fields", line: "example.com\tTRUE\t/\tFALSE\t0\tid", expectError: true},
+		{name: "invalid expiration", line: "example.com\tTRUE\t/\tFALSE\tnever\tid\t42", expectError: true},
+	}
+
+	jar := newRecordingJar()
+	for _, test := range tests {
+		entry, err := jar.parseLine(test.line)
+		if (err != nil) != test.expectError {
+			t.Errorf("%s: parseLine returned error %v, expected error: %t", test.name, err, test.expectError)
+			continue
+		}
+		switch {
+		case test.expected == nil && entry != nil:
+			t.Errorf("%s: expected no cookie, got %+v", test.name, entry)
+		case test.expected != nil && (entry == nil || *entry != *test.expected):
+			t.Errorf("%s: expected cookie %+v, got %+v", test.name, test.expected, entry)
+		}
+	}
+}
+
+func TestCookieEntryString(t *testing.T) {
+	lines := []string{
+		"www.example.com\tFALSE\t/\tFALSE\t0\tsession\tabc",
+		".example.com\tTRUE\t/novel\tTRUE\t2000000000\ttoken\tx=y",
+		"#HttpOnly_.example.com\tTRUE\t/\tFALSE\t0\tid\t42",
+	}
+	jar := newRecordingJar()
+	for _, line := range lines {
+		entry, err := jar.parseLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.String() != line {
+			t.Errorf("expected %q, got %q", line, entry.String())
+		}
+	}
+}
+
+func TestLoadAndSaveFile(t *testing.T) {
+	directory := t.TempDir()
+	fileName := filepath.Join(directory, "cookies.txt")
+	content := strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		".example.com\tTRUE\t/\tFALSE\t0\tsession\tabc",
+		"www.example.com\tFALSE\t/\tFALSE\t1000000000\texpired\tdef",
+		"",
+	}, "\n")
+	if err := ioutil.WriteFile(fileName, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	jar := newRecordingJar()
+	if err := jar.LoadFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	cookies := jar.Cookies(&url.URL{Scheme: "https", Host: "www.example.com", Path: "/chapter-1"})
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value != "abc" {
+		t.Fatalf("expected only the not expired session cookie, got %v", cookies)
+	}
+
+	savedFileName := filepath.Join(directory, "saved.txt")
+	if err := jar.SaveFile(savedFileName); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ioutil.ReadFile(savedFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# Netscape HTTP Cookie File\n.example.com\tTRUE\t/\tFALSE\t0\tsession\tabc\n"
+	if string(saved) != expected {
+		t.Errorf("expected saved cookies %q, got %q", expected, string(saved))
+	}
+
+	invalidFileName := filepath.Join(directory, "invalid.txt")
+	if err = ioutil.WriteFile(invalidFileName, []byte("# comment\nexample.com\tTRUE\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = newRecordingJar().LoadFile(invalidFileName); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("expected error with the line number of the invalid cookie, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// FetchError is returned if a request failed even after retrying it
+type FetchError struct {
+	URL string
+	// status code of the last response, 0 if the request failed without response
+	StatusCode int
+	Err        error
+}
+
+// Error returns the error message including the requested URL
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("unable to retrieve %s: %s", e.URL, e.Err)
+}
+
+// Unwrap returns the original error of the request
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// newFetchError wraps the passed error of the request to the passed URL into a FetchError
+// errors which are already fetch errors (f.e. from the login or the wayback machine) are returned unchanged
+func newFetchError(uri string, err error) error {
+	var fetchError *FetchError
+	if err == nil || errors.As(err, &fetchError) {
+		return err
+	}
+	return &FetchError{URL: uri, Err: err}
+}
+
+// newStatusError closes the body of the passed response with a client or server error status code
+// and returns a FetchError containing the status code
+func newStatusError(uri string, response *http.Response) error {
+	_ = response.Body.Close()
+	return &FetchError{
+		URL:        uri,
+		StatusCode: response.StatusCode,
+		Err:        fmt.Errorf("status code %d (%s)", response.StatusCode, http.StatusText(response.StatusCode)),
+	}
+}
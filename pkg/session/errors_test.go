@@ -0,0 +1,58 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+func TestFetchError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	s := newTestSession(t, server, 0, config.Cache{Disabled: true})
+	uri := server.URL + "/chapter-1"
+	// requests to the closed server fail with a connection error
+	server.Close()
+
+	response, err := s.Get(context.Background(), uri)
+	var fetchError *FetchError
+	if !errors.As(err, &fetchError) || fetchError.URL != uri || response != nil {
+		t.Fatalf("expected FetchError for %s, got %v", uri, err)
+	}
+	if wrapped := newFetchError("https://www.example.com", fetchError); wrapped != fetchError {
+		t.Errorf("expected fetch errors not to be wrapped again, got %v", wrapped)
+	}
+	if newFetchError(uri, nil) != nil {
+		t.Errorf("expected no error without an error of the request")
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// the status code of the last response is kept after the retries gave up
+	s := newTestSession(t, server, 1, config.Cache{Disabled: true})
+	ctx := context.Background()
+	for _, request := range []func() (*http.Response, error){
+		func() (*http.Response, error) { return s.Get(ctx, server.URL+"/chapter-1") },
+		func() (*http.Response, error) { return s.Post(ctx, server.URL+"/chapter-1", nil) },
+	} {
+		response, err := request()
+		var fetchError *FetchError
+		if !errors.As(err, &fetchError) || fetchError.StatusCode != http.StatusServiceUnavailable || response != nil {
+			t.Errorf("expected FetchError with the status code %d, got %v", http.StatusServiceUnavailable, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), "Service Unavailable") {
+			t.Errorf("expected status text in the error message, got %q", err.Error())
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package session
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+	"github.com/PuerkitoBio/goquery"
+	log "github.com/sirupsen/logrus"
+)
+
+// loginState contains the login options and the login status of a single site
+type loginState struct {
+	login    *config.Login
+	loggedIn bool
+	mutex    sync.Mutex
+}
+
+// getLoginState returns the login state of the site configuration matching the passed URL
+// returns nil if the site has no login configured
+func (s *session) getLoginState(u *url.URL) *loginState {
+	siteConfig := s.cfg.GetSiteConfigFromURL(u)
+	if siteConfig.Login == nil {
+		return nil
+	}
+
+	s.loginMutex.Lock()
+	defer s.loginMutex.Unlock()
+
+	state, exists := s.logins[siteConfig.Pattern()]
+	if !exists {
+		state = &loginState{login: siteConfig.Login}
+		s.logins[siteConfig.Pattern()] = state
+	}
+	return state
+}
+
+// ensureLogin logs in to the site of the passed URI if the site has a login configured and we are not logged in yet
+func (s *session) ensureLogin(ctx context.Context, uri string) error {
+	parsedURL, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if state := s.getLoginState(parsedURL); state != nil {
+		return s.login(ctx, state, false)
+	}
+	return nil
+}
+
+// verifyLogin checks the response for the logged in selector of the site the response is coming from
+// returns true if we had to log in again and the request has to be repeated
+// the response body is buffered to be readable again after the check
+func (s *session) verifyLogin(ctx context.Context, response *http.Response) (bool, error) {
+	state := s.getLoginState(response.Request.URL)
+	if state == nil {
+		return false, nil
+	}
+
+	state.mutex.Lock()
+	loggedIn := state.loggedIn
+	state.mutex.Unlock()
+	// we got redirected to a site we didn't log in yet
+	if !loggedIn {
+		return true, s.login(ctx, state, false)
+	}
+	if state.login.LoggedInSelector == "" {
+		return false, nil
+	}
+
+	doc, err := s.bufferDocument(response)
+	if err != nil {
+		return false, err
+	}
+	if doc.Find(state.login.LoggedInSelector).Length() > 0 {
+		return false, nil
+	}
+	log.Infof("login on %s expired, logging in again", response.Request.URL.Host)
+	return true, s.login(ctx, state, true)
+}
+
+// login runs the login flow of the passed site, forced logins are also done if we are already logged in
+func (s *session) login(ctx context.Context, state *loginState, force bool) error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if state.loggedIn && !force {
+		return nil
+	}
+	state.loggedIn = false
+
+	login := state.login
+	username, password := os.Getenv(login.UsernameEnv), os.Getenv(login.PasswordEnv)
+	if username == "" || password == "" {
+		return fmt.Errorf(
+			"login for %s requires the environment variables %s and %s", login.URL, login.UsernameEnv, login.PasswordEnv,
+		)
+	}
+
+	log.Infof("logging in on %s", login.URL)
+	response, err := s.get(ctx, login.URL, nil)
+	if err != nil {
+		return fmt.Errorf("unable to open login page %s: %w", login.URL, err)
+	}
+	doc, err := s.bufferDocument(response)
+	if err != nil {
+		return err
+	}
+
+	data := url.Values{}
+	for name, value := range login.Fields {
+		data.Set(name, value)
+	}
+	if login.CSRFSelector != "" {
+		token := doc.Find(login.CSRFSelector).First()
+		if token.Length() == 0 {
+			return fmt.Errorf("no CSRF token found on %s with selector %s", login.URL, login.CSRFSelector)
+		}
+		// input elements contain the token in the value attribute, meta elements in the content attribute
+		data.Set(token.AttrOr("name", token.AttrOr("property", "")), token.AttrOr("value", token.AttrOr("content", "")))
+	}
+	data.Set(login.UsernameField, username)
+	data.Set(login.PasswordField, password)
+
+	response, err = s.Post(ctx, s.getLoginAction(response.Request.URL, doc, login), data)
+	if err != nil {
+		return fmt.Errorf("login on %s failed: %w", login.URL, err)
+	}
+	if login.LoggedInSelector != "" {
+		doc, err = s.bufferDocument(response)
+		if err != nil {
+			return err
+		}
+		if doc.Find(login.LoggedInSelector).Length() == 0 {
+			return fmt.Errorf("login on %s failed, logged in selector %s not found", login.URL, login.LoggedInSelector)
+		}
+	}
+
+	state.loggedIn = true
+	log.Infof("logged in on %s", login.URL)
+	return nil
+}
+
+// getLoginAction returns the URL the login form is sent to
+// which is the action of the form containing the password field or the login URL if no action is set
+func (s *session) getLoginAction(loginURL *url.URL, doc *goquery.Document, login *config.Login) string {
+	form := doc.Find(fmt.Sprintf(`input[name=%q]`, login.PasswordField)).Closest("form")
+	action, exists := form.Attr("action")
+	if !exists || action == "" {
+		return loginURL.String()
+	}
+	actionURL, err := url.Parse(action)
+	if err != nil {
+		return loginURL.String()
+	}
+	return loginURL.ResolveReference(actionURL).String()
+}
+
+// bufferDocument parses the body of the passed response and replaces the body with a buffered copy
+// so the response can still be read by the caller afterwards
+func (s *session) bufferDocument(response *http.Response) (*goquery.Document, error) {
+	body, err := s.bufferBody(response)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = bytes.NewReader(body)
+	if response.Header.Get("Content-Encoding") == "gzip" {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return nil, err
+		}
+	}
+	return goquery.NewDocumentFromReader(reader)
+}
+
+// bufferBody reads the body of the passed response and replaces the body with a buffered copy
+func (s *session) bufferBody(response *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(response.Body)
+	if closeErr := response.Body.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
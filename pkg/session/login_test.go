@@ -0,0 +1,151 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+// loginTestServer is a site which only shows the chapter content to logged in users
+type loginTestServer struct {
+	*httptest.Server
+	logins     int
+	validToken string
+}
+
+func newLoginTestServer() *loginTestServer {
+	server := &loginTestServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `<form action="/session" method="post"><input type="hidden" name="csrf" value="abc">`+
+			`<input name="user"><input name="pass" type="password"></form>`)
+	})
+	mux.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.PostFormValue("csrf") != "abc" || r.PostFormValue("remember") != "1" ||
+			r.PostFormValue("user") != "reader" || r.PostFormValue("pass") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		server.logins++
+		server.validToken = fmt.Sprintf("token-%d", server.logins)
+		http.SetCookie(w, &http.Cookie{Name: "auth", Value: server.validToken, Path: "/"})
+		_, _ = fmt.Fprint(w, `<div id="user">reader</div>`)
+	})
+	mux.HandleFunc("/chapter", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("auth"); err == nil && cookie.Value == server.validToken {
+			_, _ = fmt.Fprint(w, `<div id="user">reader</div><p>content</p>`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `<p>preview</p>`)
+	})
+	server.Server = httptest.NewServer(mux)
+	return server
+}
+
+// newLoginTestSession returns a session with the login of the passed test server configured
+func newLoginTestSession(t *testing.T, server *loginTestServer) *session {
+	t.Helper()
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"TEST_LOGIN_USER": "reader", "TEST_LOGIN_PASS": "secret"} {
+		name := name
+		if err = os.Setenv(name, value); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			_ = os.Unsetenv(name)
+		})
+	}
+	novelConfig := &config.NovelConfig{
+		Cache: config.Cache{Disabled: true},
+		Sites: []config.SiteConfiguration{
+			{
+				Host: serverURL.Host, RateLimit: time.Millisecond, Burst: 10,
+				Login: &config.Login{
+					URL:              server.URL + "/login",
+					CSRFSelector:     `input[name="csrf"]`,
+					UsernameField:    "user",
+					PasswordField:    "pass",
+					UsernameEnv:      "TEST_LOGIN_USER",
+					PasswordEnv:      "TEST_LOGIN_PASS",
+					Fields:           map[string]string{"remember": "1"},
+					LoggedInSelector: "#user",
+				},
+			},
+		},
+	}
+	wrapper, err := NewSession(novelConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wrapper.(*WaybackMachineWrapper).session
+}
+
+// getBody returns the body of the passed URI using the passed session
+func getBody(t *testing.T, s *session, uri string) string {
+	t.Helper()
+	response, err := s.Get(context.Background(), uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestLogin(t *testing.T) {
+	server := newLoginTestServer()
+	defer server.Close()
+
+	s := newLoginTestSession(t, server)
+	if body := getBody(t, s, server.URL+"/chapter"); !strings.Contains(body, "content") {
+		t.Errorf("expected chapter content after logging in, got %q", body)
+	}
+	if body := getBody(t, s, server.URL+"/chapter"); !strings.Contains(body, "content") || server.logins != 1 {
+		t.Errorf("expected chapter content without logging in again, got %q after %d logins", body, server.logins)
+	}
+
+	// expire the login, the next request has to log in again and repeat the request
+	server.validToken = ""
+	if body := getBody(t, s, server.URL+"/chapter"); !strings.Contains(body, "content") || server.logins != 2 {
+		t.Errorf("expected chapter content after logging in again, got %q after %d logins", body, server.logins)
+	}
+}
+
+func TestLoginFailed(t *testing.T) {
+	ctx := context.Background()
+	server := newLoginTestServer()
+	defer server.Close()
+
+	s := newLoginTestSession(t, server)
+	if err := os.Setenv("TEST_LOGIN_PASS", "wrong"); err != nil {
+		t.Fatal(err)
+	}
+	var fetchError *FetchError
+	if _, err := s.Get(ctx, server.URL+"/chapter"); !errors.As(err, &fetchError) ||
+		fetchError.StatusCode != http.StatusForbidden || !strings.Contains(err.Error(), "login on") {
+		t.Errorf("expected failed login, got %v", err)
+	}
+
+	if err := os.Unsetenv("TEST_LOGIN_PASS"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, server.URL+"/chapter"); err == nil || !strings.Contains(err.Error(), "TEST_LOGIN_PASS") {
+		t.Errorf("expected error for missing credentials, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// default request settings for hosts without configured rate limit, burst, concurrency or retry settings
+const (
+	defaultRateLimit   = 1500 * time.Millisecond
+	defaultBurst       = 1
+	defaultMaxRetries  = 5
+	defaultConcurrency = 1
+)
+
+// hostPolicy contains the rate limiter, the concurrency limit and the retry settings of a single host
+type hostPolicy struct {
+	limiter    *rate.Limiter
+	maxRetries int
+	backoff    config.Backoff
+	// buffered channel with a slot for every request which can be sent to the host at the same time
+	slots chan struct{}
+}
+
+// policyKey identifies the request policy of a host for the matching site configuration
+// since site configurations with different path prefixes can use different settings for the same host
+type policyKey struct {
+	host string
+	site string
+}
+
+// getPolicy returns the request policy of the host of the passed URI for the matching site configuration
+// the policy is created from the matching site configuration on the first access of the host with it
+func (s *session) getPolicy(uri string) *hostPolicy {
+	parsedURL, err := url.Parse(uri)
+	if err != nil {
+		parsedURL = &url.URL{}
+	}
+	siteConfig := s.cfg.GetSiteConfigFromURL(parsedURL)
+	key := policyKey{host: strings.ToLower(parsedURL.Host), site: siteConfig.Pattern()}
+
+	s.policiesMutex.Lock()
+	defer s.policiesMutex.Unlock()
+
+	if policy, exists := s.policies[key]; exists {
+		return policy
+	}
+
+	concurrency := defaultConcurrency
+	if siteConfig.Concurrency > 0 {
+		concurrency = siteConfig.Concurrency
+	}
+	policy := &hostPolicy{
+		limiter:    rate.NewLimiter(rate.Every(defaultRateLimit), defaultBurst),
+		maxRetries: defaultMaxRetries,
+		backoff:    siteConfig.Backoff,
+		slots:      make(chan struct{}, concurrency),
+	}
+	if siteConfig.RateLimit > 0 {
+		policy.limiter.SetLimit(rate.Every(siteConfig.RateLimit))
+	}
+	if siteConfig.Burst > 0 {
+		policy.limiter.SetBurst(siteConfig.Burst)
+	}
+	if siteConfig.MaxRetries != nil {
+		// the first request is no retry, so we always have at least one try
+		policy.maxRetries = *siteConfig.MaxRetries + 1
+	}
+	s.policies[key] = policy
+	return policy
+}
+
+// acquire waits for a free request slot of the host, returns the error of the passed context if it got canceled
+func (p *hostPolicy) acquire(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees the previously acquired request slot of the host
+func (p *hostPolicy) release() {
+	<-p.slots
+}
+
+// isRetryable checks if the failed request could succeed on retrying it
+// connection errors, timeouts, rate limits and server errors are retried, other client errors are permanent
+// requests are never retried after the context got canceled
+func (s *session) isRetryable(ctx context.Context, response *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil || response == nil {
+		return true
+	}
+	switch response.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	default:
+		return response.StatusCode >= http.StatusInternalServerError
+	}
+}
+
+// waitForRetry waits before retrying the failed request based on the backoff policy of the host
+// the Retry-After header of rate limited or unavailable responses has priority over the backoff policy
+// returns the error of the passed context if the context got canceled while waiting
+func (s *session) waitForRetry(ctx context.Context, policy *hostPolicy, try int, response *http.Response) error {
+	delay := policy.backoff.GetDelay(try)
+	if response != nil {
+		if retryAfter, ok := s.getRetryAfter(response); ok {
+			delay = policy.backoff.LimitDelay(retryAfter)
+		}
+		// the response gets replaced by the next try, so we can close it already
+		_ = response.Body.Close()
+	}
+
+	log.Debugf("retrying request in %s", delay)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getRetryAfter returns the duration of the Retry-After header of 429 and 503 responses
+// the header can either contain the delay in seconds or a HTTP date
+func (s *session) getRetryAfter(response *http.Response) (time.Duration, bool) {
+	if response.StatusCode != http.StatusTooManyRequests && response.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	retryAfter := strings.TrimSpace(response.Header.Get("Retry-After"))
+	if retryAfter == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,109 @@
+package session
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RevalidationStats contains the amount of not modified and downloaded responses of a single host
+type RevalidationStats struct {
+	Host string
+	// responses with status code 304 where the cached body got reused
+	NotModified int
+	// responses with status code 200 where the body got downloaded
+	Downloaded int
+}
+
+// revalidationCounter counts the not modified and downloaded responses per host
+type revalidationCounter struct {
+	stats map[string]*RevalidationStats
+	mutex sync.Mutex
+}
+
+// record counts the passed status code for the host of the passed URL
+func (c *revalidationCounter) record(u *url.URL, statusCode int) {
+	if statusCode != http.StatusOK && statusCode != http.StatusNotModified {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	host := strings.ToLower(u.Host)
+	stats, exists := c.stats[host]
+	if !exists {
+		stats = &RevalidationStats{Host: host}
+		c.stats[host] = stats
+	}
+	if statusCode == http.StatusNotModified {
+		stats.NotModified++
+	} else {
+		stats.Downloaded++
+	}
+}
+
+// RevalidationReport returns the amount of not modified and downloaded responses per host sorted by the host
+func (s *session) RevalidationReport() []RevalidationStats {
+	s.revalidations.mutex.Lock()
+	defer s.revalidations.mutex.Unlock()
+
+	report := make([]RevalidationStats, 0, len(s.revalidations.stats))
+	for _, stats := range s.revalidations.stats {
+		report = append(report, *stats)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Host < report[j].Host
+	})
+	return report
+}
+
+// getConditionalHeader returns the If-None-Match and If-Modified-Since headers from the validators of the stale entry
+// returns nil if there is no stale entry or the entry has no validators
+func (s *session) getConditionalHeader(stale *cacheEntry) http.Header {
+	if stale == nil {
+		return nil
+	}
+
+	header := make(http.Header)
+	if etag := stale.Header.Get("ETag"); etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	if lastModified := stale.Header.Get("Last-Modified"); lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+	}
+	if len(header) == 0 {
+		log.Debugf("cached response of %s has no validators, requesting the full response", stale.URL)
+		return nil
+	}
+	log.Debugf("revalidating cached response of %s", stale.URL)
+	return header
+}
+
+// useNotModifiedResponse updates the stale entry with the headers of the not modified response
+// and returns the cached response instead of the empty response body
+func (s *session) useNotModifiedResponse(stale *cacheEntry, response *http.Response) (*http.Response, error) {
+	_ = response.Body.Close()
+	log.Debugf("%s not modified, using cached response", stale.URL)
+
+	// the not modified response contains the updated validators and caching headers of the stored response
+	if stale.Header == nil {
+		stale.Header = make(http.Header)
+	}
+	for name, values := range response.Header {
+		// the content length of the not modified response doesn't describe the stored body
+		if name != "Content-Length" {
+			stale.Header[name] = values
+		}
+	}
+	stale.Created = time.Now()
+	if err := s.cache.storeEntry(stale); err != nil {
+		log.Warningf("unable to update cached response of %s: %s", stale.URL, err.Error())
+	}
+	return stale.response()
+}
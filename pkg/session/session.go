@@ -6,31 +6,40 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
-	"time"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/DaRealFreak/epub-scraper/pkg/config"
-	"github.com/DaRealFreak/epub-scraper/pkg/raven"
 	"github.com/PuerkitoBio/goquery"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/time/rate"
 )
 
 // Session is the interface for the implemented HTTP client
 type Session interface {
-	Get(uri string) (response *http.Response, err error)
-	Post(uri string, data url.Values) (response *http.Response, err error)
-	GetDocument(response *http.Response) *goquery.Document
-	ApplyRateLimit()
+	Get(ctx context.Context, uri string, opts ...RequestOption) (response *http.Response, err error)
+	Post(ctx context.Context, uri string, data url.Values) (response *http.Response, err error)
+	GetDocument(response *http.Response) (*goquery.Document, error)
+	ApplyRateLimit(ctx context.Context, uri string) error
+	RevalidationReport() []RevalidationStats
+	Close() error
 }
 
 // Session is an extension to the implemented SessionInterface for HTTP sessions
 type session struct {
-	Client      *http.Client
-	RateLimiter *rate.Limiter
-	MaxRetries  int
-	ctx         context.Context
+	Client *http.Client
+	jar    *recordingJar
+	cfg    *config.NovelConfig
+	// rate limiters and retry settings of the already accessed hosts by their matching site configuration
+	policies      map[policyKey]*hostPolicy
+	policiesMutex sync.Mutex
+	// login states of the sites with a configured login
+	logins     map[string]*loginState
+	loginMutex sync.Mutex
+	// persistent response cache, nil if the cache is disabled
+	cache         *responseCache
+	revalidations revalidationCounter
 }
 
 // UseWaybackMachineError custom error if we get redirected on a URL configured to use the wayback machine
@@ -41,50 +50,183 @@ type UseWaybackMachineError struct {
 }
 
 // NewSession initializes a new session and sets all the required headers etc
-func NewSession(novelConfig *config.NovelConfig) Session {
-	jar, _ := cookiejar.New(nil)
-
-	app := session{
-		Client:      &http.Client{Jar: jar},
-		RateLimiter: rate.NewLimiter(rate.Every(1500*time.Millisecond), 1),
-		MaxRetries:  5,
-		ctx:         context.Background(),
+// returns an error if the cookie file can't be loaded or the cache can't be used in offline mode
+func NewSession(novelConfig *config.NovelConfig) (Session, error) {
+	jar := newRecordingJar()
+	if novelConfig.Cookies.File != "" {
+		err := jar.LoadFile(novelConfig.Cookies.File)
+		switch {
+		case err == nil:
+			log.Infof("loaded cookies from %s", novelConfig.Cookies.File)
+		case os.IsNotExist(err) && novelConfig.Cookies.Save:
+			// the cookie file doesn't have to exist yet if we are saving the cookies after the run
+			log.Infof("cookie file %s doesn't exist yet and will be created after the run", novelConfig.Cookies.File)
+		default:
+			return nil, fmt.Errorf("unable to load cookies: %w", err)
+		}
 	}
 
-	return &WaybackMachineWrapper{
-		session: app,
-		cfg:     novelConfig,
+	var cache *responseCache
+	if !novelConfig.Cache.Disabled {
+		var err error
+		cache, err = newResponseCache(novelConfig.Cache.Directory)
+		switch {
+		case err == nil:
+			log.Debugf("using response cache in %s", cache.directory)
+		case novelConfig.Cache.Offline:
+			return nil, fmt.Errorf("unable to use response cache in offline mode: %w", err)
+		default:
+			log.Warningf("unable to use response cache, continuing without cache: %s", err.Error())
+		}
+	} else if novelConfig.Cache.Offline {
+		return nil, fmt.Errorf("offline mode requires the response cache to be enabled")
 	}
+
+	wrapper := &WaybackMachineWrapper{session: session{
+		Client:   &http.Client{Jar: jar, Transport: newSiteTransport(novelConfig)},
+		jar:      jar,
+		cfg:      novelConfig,
+		policies: make(map[policyKey]*hostPolicy),
+		logins:   make(map[string]*loginState),
+		cache:    cache,
+		revalidations: revalidationCounter{
+			stats: make(map[string]*RevalidationStats),
+		},
+	}}
+	// check every redirect of the client for hosts which should use the wayback machine
+	wrapper.Client.CheckRedirect = wrapper.checkRedirect
+	return wrapper, nil
 }
 
 // Get sends a GET request, returns the occurred error if something went wrong even after multiple tries
-func (s *session) Get(uri string) (response *http.Response, err error) {
+// sites with a configured login are logged in before the first request and logged in again on expired logins
+// successful responses are stored in the response cache and returned from it until they expire,
+// expired responses are revalidated with conditional requests if the response contained an ETag or Last-Modified header
+// requests are aborted with the error of the passed context after the context got canceled
+// failed requests return a FetchError
+func (s *session) Get(ctx context.Context, uri string, opts ...RequestOption) (response *http.Response, err error) {
+	response, err = s.getCached(ctx, uri, opts...)
+	return response, newFetchError(uri, err)
+}
+
+// getCached returns the response from the response cache or sends the request and updates the cache
+func (s *session) getCached(ctx context.Context, uri string, opts ...RequestOption) (response *http.Response, err error) {
+	var stale *cacheEntry
+	if s.cache != nil {
+		var cachedResponse *http.Response
+		cachedResponse, stale, err = s.getCachedResponse(uri, newRequestOptions(opts))
+		if err != nil || cachedResponse != nil {
+			return cachedResponse, err
+		}
+	}
+
+	response, err = s.getWithLogin(ctx, uri, s.getConditionalHeader(stale))
+	if err != nil || s.cache == nil {
+		return response, err
+	}
+	s.revalidations.record(response.Request.URL, response.StatusCode)
+
+	if response.StatusCode == http.StatusNotModified && stale != nil {
+		return s.useNotModifiedResponse(stale, response)
+	}
+	if response.StatusCode != http.StatusOK {
+		return response, nil
+	}
+
+	body, err := s.bufferBody(response)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.cache.store(uri, response, body); err != nil {
+		log.Warningf("unable to cache response of %s: %s", uri, err.Error())
+	}
+	return response, nil
+}
+
+// getWithLogin sends a GET request and ensures that we are logged in on sites with a configured login
+func (s *session) getWithLogin(ctx context.Context, uri string, header http.Header) (response *http.Response, err error) {
+	if err = s.ensureLogin(ctx, uri); err != nil {
+		return nil, err
+	}
+
+	response, err = s.get(ctx, uri, header)
+	// not modified responses have no body to check the login state with
+	if err != nil || response.StatusCode == http.StatusNotModified {
+		return response, err
+	}
+
+	repeat, err := s.verifyLogin(ctx, response)
+	if err != nil {
+		return nil, err
+	}
+	if repeat {
+		_ = response.Body.Close()
+		return s.get(ctx, uri, header)
+	}
+	return response, nil
+}
+
+// get sends a GET request with the passed additional headers and the retry and backoff policy of the host
+// without checking the login state
+func (s *session) get(ctx context.Context, uri string, header http.Header) (response *http.Response, err error) {
 	// access the passed url and return the data or the error which persisted multiple retries
 	// post the request with the retries option
-	for try := 1; try <= s.MaxRetries; try++ {
-		s.ApplyRateLimit()
+	policy := s.getPolicy(uri)
+	for try := 1; try <= policy.maxRetries; try++ {
+		if err = policy.acquire(ctx); err != nil {
+			return nil, err
+		}
+		if err = s.ApplyRateLimit(ctx, uri); err != nil {
+			policy.release()
+			return nil, err
+		}
 		log.Debug(fmt.Sprintf("opening GET uri \"%s\" (try: %d)", uri, try))
-		response, err = s.Client.Get(uri)
+		response, err = s.sendGet(ctx, uri, header)
+		policy.release()
 		if err == nil && response.StatusCode < 400 {
 			// if no error occurred and status code is okay too break out of the loop
 			// 4xx & 5xx are client/server error codes, so we check for < 400
 			return response, err
 		}
 
-		if waybackResponse, done, err := s.handleWaybackMachineError(response, err); done {
+		if waybackResponse, done, err := s.handleWaybackMachineError(ctx, response, err); done {
 			return waybackResponse, err
 		}
 
+		// permanent errors like 404 won't change on retrying
+		if !s.isRetryable(ctx, response, err) || try == policy.maxRetries {
+			break
+		}
 		// any other error falls into the retry clause
-		time.Sleep(time.Duration(try+1) * time.Second)
+		if err = s.waitForRetry(ctx, policy, try, response); err != nil {
+			return nil, err
+		}
+	}
+	// the retry policy gave up on the client or server error status code
+	if err == nil && response != nil && response.StatusCode >= 400 {
+		return nil, newStatusError(uri, response)
 	}
 	return response, err
 }
 
+// sendGet sends a single GET request with the passed additional headers
+func (s *session) sendGet(ctx context.Context, uri string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	return s.Client.Do(req)
+}
+
 // handleWaybackMachineError checks if the returned error is indicating that we should use the wayback machine
 // if yes we return the request using the wayback machine and replace the request URL to the original URL
 // to keep host settings
-func (s *session) handleWaybackMachineError(response *http.Response, err error) (*http.Response, bool, error) {
+func (s *session) handleWaybackMachineError(
+	ctx context.Context, response *http.Response, err error,
+) (*http.Response, bool, error) {
 	if response != nil && err != nil {
 		// can't use .(type) outside of switch case, so we have to use single case switch case here
 		// nolint: gocritic
@@ -92,8 +234,8 @@ func (s *session) handleWaybackMachineError(response *http.Response, err error)
 		case *url.Error:
 			switch c := v.Err.(type) {
 			case *UseWaybackMachineError:
-				newURL := fmt.Sprintf("https://web.archive.org/web/%s/%s", c.Handling.Version, c.URL.String())
-				newRes, err := s.Get(newURL)
+				newURL := fmt.Sprintf("https://%s/web/%s/%s", waybackMachineHost, c.Handling.Version, c.URL.String())
+				newRes, err := s.Get(ctx, newURL)
 				if newRes != nil {
 					newRes.Request.URL = c.URL
 					return newRes, true, err
@@ -105,46 +247,94 @@ func (s *session) handleWaybackMachineError(response *http.Response, err error)
 }
 
 // Post sends a POST request, returns the occurred error if something went wrong even after multiple tries
-func (s *session) Post(uri string, data url.Values) (response *http.Response, err error) {
+// failed requests return a FetchError
+func (s *session) Post(ctx context.Context, uri string, data url.Values) (response *http.Response, err error) {
+	response, err = s.post(ctx, uri, data)
+	return response, newFetchError(uri, err)
+}
+
+// post sends a POST request with the retry and backoff policy of the host
+func (s *session) post(ctx context.Context, uri string, data url.Values) (response *http.Response, err error) {
 	// post the request with the retries option
-	for try := 1; try <= s.MaxRetries; try++ {
-		s.ApplyRateLimit()
+	policy := s.getPolicy(uri)
+	for try := 1; try <= policy.maxRetries; try++ {
+		if err = policy.acquire(ctx); err != nil {
+			return nil, err
+		}
+		if err = s.ApplyRateLimit(ctx, uri); err != nil {
+			policy.release()
+			return nil, err
+		}
 		log.Debug(fmt.Sprintf("opening POST uri \"%s\" (try: %d)", uri, try))
-		response, err = s.Client.PostForm(uri, data)
+		response, err = s.sendPost(ctx, uri, data)
+		policy.release()
 		switch {
 		case err == nil && response.StatusCode < 400:
 			// if no error occurred and status code is okay too break out of the loop
 			// 4xx & 5xx are client/server error codes, so we check for < 400
 			return response, err
+		case !s.isRetryable(ctx, response, err) || try == policy.maxRetries:
+			// permanent errors like 404 won't change on retrying
+			if err == nil {
+				return nil, newStatusError(uri, response)
+			}
+			return response, err
 		default:
 			// any other error falls into the retry clause
-			time.Sleep(time.Duration(try+1) * time.Second)
+			if err = s.waitForRetry(ctx, policy, try, response); err != nil {
+				return nil, err
+			}
 		}
 	}
 	return response, err
 }
 
-// GetDocument converts the http response to a *goquery.Document
-func (s *session) GetDocument(response *http.Response) *goquery.Document {
-	var reader io.ReadCloser
-	switch response.Header.Get("Content-Encoding") {
-	case "gzip":
-		reader, _ = gzip.NewReader(response.Body)
-	default:
-		reader = response.Body
+// sendPost sends a single POST request with the passed URL encoded form data
+func (s *session) sendPost(ctx context.Context, uri string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
 	}
-	defer raven.CheckClosure(reader)
-	document, err := goquery.NewDocumentFromReader(reader)
-	raven.CheckError(err)
-	return document
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.Client.Do(req)
+}
+
+// Close saves the cookies of the session to the cookie file if configured
+func (s *session) Close() error {
+	if s.cfg.Cookies.File == "" || !s.cfg.Cookies.Save {
+		return nil
+	}
+	if err := s.jar.SaveFile(s.cfg.Cookies.File); err != nil {
+		return err
+	}
+	log.Infof("saved cookies to %s", s.cfg.Cookies.File)
+	return nil
 }
 
-// ApplyRateLimit waits for the leaky bucket to fill again
-func (s *session) ApplyRateLimit() {
-	// if no rate limiter is defined we don't have to wait
-	if s.RateLimiter != nil {
-		// wait for request to stay within the rate limit
-		err := s.RateLimiter.Wait(s.ctx)
-		raven.CheckError(err)
+// GetDocument converts the http response to a *goquery.Document and closes the response body
+func (s *session) GetDocument(response *http.Response) (*goquery.Document, error) {
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	var reader io.Reader = response.Body
+	if response.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(response.Body)
+		if err != nil {
+			return nil, newFetchError(response.Request.URL.String(), err)
+		}
+		reader = gzipReader
 	}
+	document, err := goquery.NewDocumentFromReader(reader)
+	if err != nil {
+		return nil, newFetchError(response.Request.URL.String(), err)
+	}
+	return document, nil
+}
+
+// ApplyRateLimit waits for the leaky bucket of the host of the passed URI to fill again
+// returns the error of the passed context if the context got canceled while waiting
+func (s *session) ApplyRateLimit(ctx context.Context, uri string) error {
+	// wait for request to stay within the rate limit of the host
+	return s.getPolicy(uri).limiter.Wait(ctx)
 }
@@ -0,0 +1,155 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+// newTestSession returns a session for the passed test server without rate limit and the passed retries and cache
+func newTestSession(t *testing.T, server *httptest.Server, maxRetries int, cache config.Cache) *session {
+	t.Helper()
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	novelConfig := &config.NovelConfig{
+		Cache: cache,
+		Sites: []config.SiteConfiguration{
+			{Host: serverURL.Host, RateLimit: time.Millisecond, Burst: 10, MaxRetries: &maxRetries},
+		},
+	}
+	wrapper, err := NewSession(novelConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wrapper.(*WaybackMachineWrapper).session
+}
+
+func TestGetPolicy(t *testing.T) {
+	maxRetries := 2
+	wrapper, err := NewSession(&config.NovelConfig{
+		Cache: config.Cache{Disabled: true},
+		Sites: []config.SiteConfiguration{
+			{
+				Host: "example.com", RateLimit: 2 * time.Second, Burst: 3, MaxRetries: &maxRetries,
+				Backoff: config.Backoff{Strategy: config.BackoffConstant},
+			},
+			{Host: "example.com", PathPrefix: "/premium", Burst: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := wrapper.(*WaybackMachineWrapper)
+
+	policy := s.getPolicy("https://example.com/chapter-1")
+	if policy.maxRetries != 3 || policy.limiter.Burst() != 3 || policy.backoff.Strategy != config.BackoffConstant {
+		t.Errorf("unexpected policy of configured host: %+v", policy)
+	}
+	if s.getPolicy("https://EXAMPLE.com/chapter-2") != policy {
+		t.Errorf("expected the policy to be reused for the same host")
+	}
+	// site configurations with path prefixes use their own policy for the same host
+	premiumPolicy := s.getPolicy("https://example.com/premium/chapter-1")
+	if premiumPolicy == policy || premiumPolicy.limiter.Burst() != 1 {
+		t.Errorf("expected own policy of the site configuration with path prefix, got %+v", premiumPolicy)
+	}
+	if s.getPolicy("https://example.com/premium/chapter-2") != premiumPolicy {
+		t.Errorf("expected the policy to be reused for the same site configuration")
+	}
+
+	policy = s.getPolicy("https://other.example.org/")
+	if policy.maxRetries != defaultMaxRetries || policy.limiter.Burst() != defaultBurst {
+		t.Errorf("expected default policy of not configured host, got %+v", policy)
+	}
+}
+
+func TestGetRetries(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case requests < 3:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	s := newTestSession(t, server, 2, config.Cache{Disabled: true})
+	response, err := s.Get(context.Background(), server.URL+"/chapter-1")
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("expected successful response after retries, got %v, %v", response, err)
+	}
+	_ = response.Body.Close()
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+
+	// permanent errors are not retried
+	requests = 0
+	_, err = s.Get(context.Background(), server.URL+"/missing")
+	var fetchError *FetchError
+	if !errors.As(err, &fetchError) || fetchError.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected FetchError with the status code %d, got %v", http.StatusNotFound, err)
+	}
+	if requests != 1 {
+		t.Errorf("expected no retries on permanent errors, got %d requests", requests)
+	}
+}
+
+func TestGetRetryAfter(t *testing.T) {
+	tests := []struct {
+		status     int
+		retryAfter string
+		expected   time.Duration
+		ok         bool
+	}{
+		{http.StatusTooManyRequests, "3", 3 * time.Second, true},
+		{http.StatusServiceUnavailable, time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, true},
+		{http.StatusTooManyRequests, "", 0, false},
+		{http.StatusTooManyRequests, "soon", 0, false},
+		{http.StatusInternalServerError, "3", 0, false},
+	}
+
+	s := &session{}
+	for _, test := range tests {
+		response := &http.Response{StatusCode: test.status, Header: http.Header{}}
+		response.Header.Set("Retry-After", test.retryAfter)
+		delay, ok := s.getRetryAfter(response)
+		if delay != test.expected || ok != test.ok {
+			t.Errorf(
+				"Retry-After %q with status %d returned %s, %t, expected %s, %t",
+				test.retryAfter, test.status, delay, ok, test.expected, test.ok,
+			)
+		}
+	}
+}
+
+func TestGetCanceled(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := newTestSession(t, server, 2, config.Cache{Disabled: true})
+	if _, err := s.Get(ctx, server.URL+"/chapter-1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled request, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests with a canceled context, got %d", requests)
+	}
+}
@@ -0,0 +1,117 @@
+package session
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+// siteTransport is a http.RoundTripper applying the HTTP options of the site configuration matching the request URL
+// since the client passes every redirect through the transport the options are also applied on redirects
+type siteTransport struct {
+	cfg *config.NovelConfig
+	// default transport for sites without TLS options
+	transport *http.Transport
+	// transports of sites with custom TLS options by their host and path pattern
+	tlsTransports map[string]*http.Transport
+	mutex         sync.Mutex
+}
+
+// newSiteTransport returns a new site transport for the passed novel configuration
+func newSiteTransport(cfg *config.NovelConfig) *siteTransport {
+	t := &siteTransport{
+		cfg:           cfg,
+		transport:     http.DefaultTransport.(*http.Transport).Clone(),
+		tlsTransports: make(map[string]*http.Transport),
+	}
+	// the proxy is selected for every request, transports with TLS options are cloned and keep the proxy function
+	t.transport.Proxy = t.proxy
+	return t
+}
+
+// proxy returns the proxy for the passed request, the site proxy has priority over the global proxy
+// requests to the wayback machine use the wayback machine proxy of the archived site if configured
+// the proxy environment variables are used if no proxy is configured at all
+func (t *siteTransport) proxy(req *http.Request) (*url.URL, error) {
+	if archivedURL, ok := getArchivedURL(req.URL); ok {
+		if archivedSite := t.cfg.GetSiteConfigFromURL(archivedURL); archivedSite.WaybackMachine.Proxy.IsSet() {
+			return archivedSite.WaybackMachine.Proxy.URL, nil
+		}
+	}
+	if siteConfig := t.cfg.GetSiteConfigFromURL(req.URL); siteConfig.Proxy.IsSet() {
+		return siteConfig.Proxy.URL, nil
+	}
+	if t.cfg.Proxy.IsSet() {
+		return t.cfg.Proxy.URL, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// RoundTrip implements the http.RoundTripper interface and sends the request with the options of the matching site
+func (t *siteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	siteConfig := t.cfg.GetSiteConfigFromURL(req.URL)
+	transport, err := t.getTransport(siteConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// round trippers are not allowed to modify the passed request
+	req = req.Clone(req.Context())
+	for name, value := range siteConfig.HTTP.Headers {
+		req.Header.Set(name, value)
+	}
+	if siteConfig.HTTP.UserAgent != "" {
+		req.Header.Set("User-Agent", siteConfig.HTTP.UserAgent)
+	}
+	for name, value := range siteConfig.HTTP.Cookies {
+		// cookies set by the site itself have priority over the static cookies
+		if _, err := req.Cookie(name); err == http.ErrNoCookie {
+			req.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+	}
+	return transport.RoundTrip(req)
+}
+
+// getTransport returns the transport for the passed site configuration
+// sites with a custom CA bundle or skipped certificate verification get their own transport
+func (t *siteTransport) getTransport(siteConfig *config.SiteConfiguration) (*http.Transport, error) {
+	if !siteConfig.HTTP.HasTLSOptions() {
+		return t.transport, nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if transport, exists := t.tlsTransports[siteConfig.Pattern()]; exists {
+		return transport, nil
+	}
+
+	// #nosec: insecure-skip-verify has to be explicitly enabled in the site configuration
+	tlsConfig := &tls.Config{InsecureSkipVerify: siteConfig.HTTP.InsecureSkipVerify}
+	if siteConfig.HTTP.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		bundle, err := ioutil.ReadFile(filepath.Clean(siteConfig.HTTP.CABundle))
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", siteConfig.HTTP.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := t.transport.Clone()
+	transport.TLSClientConfig = tlsConfig
+	t.tlsTransports[siteConfig.Pattern()] = transport
+	return transport, nil
+}
@@ -0,0 +1,50 @@
+package session
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/DaRealFreak/epub-scraper/pkg/config"
+)
+
+func TestProxySelection(t *testing.T) {
+	proxy := func(rawURL string) config.Proxy {
+		proxyURL, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return config.Proxy{URL: proxyURL}
+	}
+	transport := newSiteTransport(&config.NovelConfig{
+		Proxy: proxy("http://global.example.com:3128"),
+		Sites: []config.SiteConfiguration{
+			{Host: "www.example.com", Proxy: proxy("socks5://site.example.com:1080")},
+			{
+				Host:           "archived.example.com",
+				WaybackMachine: config.WaybackMachine{Use: true, Proxy: proxy("http://archive.example.com:3128")},
+			},
+		},
+	})
+
+	tests := []struct {
+		uri      string
+		expected string
+	}{
+		{"https://www.example.com/chapter-1", "socks5://site.example.com:1080"},
+		{"https://other.example.org/chapter-1", "http://global.example.com:3128"},
+		{"https://web.archive.org/web/2020/https://archived.example.com/chapter-1", "http://archive.example.com:3128"},
+		{"https://web.archive.org/web/2020/https://www.example.com/chapter-1", "http://global.example.com:3128"},
+		{"https://archived.example.com/chapter-1", "http://global.example.com:3128"},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, test.uri, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxyURL, err := transport.proxy(req)
+		if err != nil || proxyURL == nil || proxyURL.String() != test.expected {
+			t.Errorf("expected proxy %s for %s, got %v (error: %v)", test.expected, test.uri, proxyURL, err)
+		}
+	}
+}
@@ -1,24 +1,26 @@
 package session
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
-
-	"github.com/DaRealFreak/epub-scraper/pkg/config"
-	"github.com/DaRealFreak/epub-scraper/pkg/raven"
+	"strings"
 )
 
-// WaybackMachineWrapper contains wayback machine related variables like the session and the novel configuration
+// host of the wayback machine
+const waybackMachineHost = "web.archive.org"
+
+// WaybackMachineWrapper contains wayback machine related functionality wrapped around the session
+// the redirect check is set once on creating the session, so the wrapper can be used concurrently
 type WaybackMachineWrapper struct {
 	session
-	cfg             *config.NovelConfig
-	sessionRedirect func(req *http.Request, via []*http.Request) error
 }
 
-// checkRedirect checks the passed request for token fragments and returns http.ErrUseLastResponse if found
-// this causes the client to not follow the redirect, enabling us to use non-existing URLs as redirect URL
+// checkRedirect checks the passed request for hosts configured to use the wayback machine
+// and returns an UseWaybackMachineError if found, which causes the client to not follow the redirect
+// enabling us to use non-existing URLs as redirect URL
 func (w *WaybackMachineWrapper) checkRedirect(req *http.Request, via []*http.Request) error {
 	siteConfig := w.cfg.GetSiteConfigFromURL(req.URL)
 	if siteConfig.WaybackMachine.Use {
@@ -28,40 +30,50 @@ func (w *WaybackMachineWrapper) checkRedirect(req *http.Request, via []*http.Req
 		}
 	}
 
-	// return the previously set redirect function
-	if w.sessionRedirect != nil {
-		return w.sessionRedirect(req, via)
-	}
-	// session redirect can be nil too, fallback to default http.Client -> defaultCheckRedirect
+	// fallback to the default redirect policy of the http.Client -> defaultCheckRedirect
 	if len(via) >= 10 {
 		return errors.New("stopped after 10 redirects")
 	}
 	return nil
 }
 
-// Get performs a normal GET request but checks the redirects to a host which should use the wayback machine
-func (w *WaybackMachineWrapper) Get(uri string) (response *http.Response, err error) {
-	// save original check redirect function and replace it with our custom one
-	w.sessionRedirect = w.session.Client.CheckRedirect
-	w.session.Client.CheckRedirect = w.checkRedirect
-
+// Get performs a normal GET request but uses the wayback machine for hosts configured to use it
+func (w *WaybackMachineWrapper) Get(
+	ctx context.Context, uri string, opts ...RequestOption,
+) (response *http.Response, err error) {
 	// check direct passed URL for wayback machine host option and update url if required
 	parsedURL, err := url.Parse(uri)
-	raven.CheckError(err)
+	if err != nil {
+		return nil, newFetchError(uri, err)
+	}
 	siteConfig := w.cfg.GetSiteConfigFromURL(parsedURL)
 	if siteConfig.WaybackMachine.Use {
-		uri = fmt.Sprintf("https://web.archive.org/web/%s/%s", siteConfig.WaybackMachine.Version, uri)
+		uri = fmt.Sprintf("https://%s/web/%s/%s", waybackMachineHost, siteConfig.WaybackMachine.Version, uri)
 	}
 
 	// make the get request
-	response, err = w.session.Get(uri)
+	response, err = w.session.Get(ctx, uri, opts...)
 
 	// if we previously updated the uri we restore the original request URL again for host settings
 	if response != nil && siteConfig.WaybackMachine.Use {
 		response.Request.URL = parsedURL
 	}
-
-	// restore original check redirect function
-	w.session.Client.CheckRedirect = w.sessionRedirect
 	return response, err
 }
+
+// getArchivedURL returns the original URL of the passed wayback machine URL
+// wayback machine URLs have the format https://web.archive.org/web/[version]/[original URL]
+func getArchivedURL(u *url.URL) (*url.URL, bool) {
+	if !strings.EqualFold(u.Hostname(), waybackMachineHost) || !strings.HasPrefix(u.Path, "/web/") {
+		return nil, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/web/"), "/", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	archivedURL, err := url.Parse(parts[1])
+	if err != nil || archivedURL.Host == "" {
+		return nil, false
+	}
+	return archivedURL, true
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/DaRealFreak/epub-scraper/pkg/raven"
 	"github.com/DaRealFreak/epub-scraper/pkg/version"
 	"github.com/blang/semver"
 	"github.com/rhysd/go-github-selfupdate/selfupdate"
@@ -24,8 +23,11 @@ func NewUpdateChecker() *Checker {
 // CheckForAvailableUpdates checks if any new releases exist and prints a notification if there is a new release
 func (u *Checker) CheckForAvailableUpdates() {
 	// check for available updates
+	// failed update checks are already logged and shouldn't prevent the usage without network access
 	updateAvailable, err := u.isUpdateAvailable()
-	raven.CheckError(err)
+	if err != nil {
+		return
+	}
 	if updateAvailable {
 		fmt.Println("new version detected, run \"epub-scraper update\" to update your application.")
 	}